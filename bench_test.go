@@ -0,0 +1,76 @@
+package gokdl_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/lunjon/gokdl/internal/benchdata"
+)
+
+// The benchmarks are named by shape and size of the document,
+// e.g. BenchmarkParse/deep-medium, so that results from
+// different revisions can be compared using benchstat:
+//
+//	go test -run='^$' -bench=. -count=10 ./... > old.txt
+//	go test -run='^$' -bench=. -count=10 ./... > new.txt
+//	benchstat old.txt new.txt
+
+func BenchmarkParse(b *testing.B) {
+	benchmarkDocuments(b, func(doc string) error {
+		_, err := gokdl.Parse(strings.NewReader(doc))
+		return err
+	})
+}
+
+func BenchmarkParseString(b *testing.B) {
+	benchmarkDocuments(b, func(doc string) error {
+		_, err := gokdl.ParseString(doc)
+		return err
+	})
+}
+
+func BenchmarkReader(b *testing.B) {
+	benchmarkDocuments(b, func(doc string) error {
+		r := gokdl.NewReader(strings.NewReader(doc))
+		defer r.Close()
+		for {
+			_, err := r.Next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func TestBenchmarkDocuments(t *testing.T) {
+	for _, shape := range benchdata.Shapes {
+		t.Run(string(shape), func(t *testing.T) {
+			doc := benchdata.Generate(shape, benchdata.Sizes[1].Bytes)
+			if _, err := gokdl.ParseString(doc); err != nil {
+				t.Fatalf("expected no error but was: %s", err)
+			}
+		})
+	}
+}
+
+func benchmarkDocuments(b *testing.B, parse func(string) error) {
+	for _, shape := range benchdata.Shapes {
+		for _, size := range benchdata.Sizes {
+			doc := benchdata.Generate(shape, size.Bytes)
+			b.Run(fmt.Sprintf("%s-%s", shape, size.Name), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(doc)))
+				for i := 0; i < b.N; i++ {
+					if err := parse(doc); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
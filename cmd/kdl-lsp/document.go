@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lunjon/gokdl"
+	"github.com/lunjon/gokdl/lexer"
+)
+
+// document is an open text document.
+// LSP positions are in lines and UTF-16 code units,
+// so the offsets of the line starts are kept to convert
+// byte offsets to LSP positions and back.
+type document struct {
+	text       string
+	lineStarts []int
+	tokens     []lexer.Token
+}
+
+func newDocument(text string) *document {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+			starts = append(starts, i+1)
+		case '\n':
+			starts = append(starts, i+1)
+		}
+	}
+
+	return &document{
+		text:       text,
+		lineStarts: starts,
+		tokens:     lexer.Tokenize(text),
+	}
+}
+
+// position returns the LSP position of the byte offset.
+func (d *document) position(offset int) position {
+	offset = min(max(offset, 0), len(d.text))
+	line := sort.SearchInts(d.lineStarts, offset+1) - 1
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16Len(r)
+	}
+	return position{Line: line, Character: character}
+}
+
+// offset returns the byte offset of the LSP position.
+func (d *document) offset(pos position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[pos.Line]
+	character := 0
+	for _, r := range d.text[offset:] {
+		if character >= pos.Character || r == '\n' || r == '\r' {
+			break
+		}
+		character += utf16Len(r)
+		offset += utf8.RuneLen(r)
+	}
+	return offset
+}
+
+func (d *document) rangeOf(start, end int) lspRange {
+	return lspRange{Start: d.position(start), End: d.position(end)}
+}
+
+// trimEnd moves the offset back over trailing whitespace
+// and semicolons, i.e. to the end of the node terminated there.
+func (d *document) trimEnd(offset int) int {
+	trimmed := strings.TrimRightFunc(d.text[:offset], func(r rune) bool {
+		return r == ';' || unicode.IsSpace(r)
+	})
+	return len(trimmed)
+}
+
+// diagnostics returns the errors and warnings of the document.
+func (d *document) diagnostics() []diagnostic {
+	diags := d.shadowedProps()
+	_, err := gokdl.ParseString(d.text)
+	if err == nil {
+		return diags
+	}
+
+	offset := 0
+	var perr *gokdl.ParseError
+	if errors.As(err, &perr) {
+		offset = perr.Pos.Offset
+		err = perr.Err
+	}
+
+	end := offset
+	if _, size := utf8.DecodeRuneInString(d.text[min(offset, len(d.text)):]); size > 0 {
+		end += size
+	}
+
+	return append(diags, diagnostic{
+		Range:    d.rangeOf(offset, end),
+		Severity: severityError,
+		Source:   "kdl",
+		Message:  err.Error(),
+	})
+}
+
+// shadowedProps returns a warning for each property
+// that is overridden by a later property of the node.
+func (d *document) shadowedProps() []diagnostic {
+	diags := []diagnostic{}
+	// The offsets of the properties by name for each started node
+	var stack []map[string]int
+
+	d.read(func(ev gokdl.Event, pos gokdl.Position) {
+		switch ev := ev.(type) {
+		case gokdl.StartNode:
+			stack = append(stack, map[string]int{})
+		case gokdl.EndNode:
+			stack = stack[:len(stack)-1]
+		case gokdl.Prop:
+			props := stack[len(stack)-1]
+			if offset, ok := props[ev.Name]; ok {
+				name := d.nameToken(offset)
+				diags = append(diags, diagnostic{
+					Range:    d.rangeOf(name.Start, name.End),
+					Severity: severityWarning,
+					Source:   "kdl",
+					Message:  fmt.Sprintf("property %s is shadowed by a later property with the same name", ev.Name),
+				})
+			}
+			props[ev.Name] = pos.Offset
+		}
+	})
+
+	return diags
+}
+
+// symbols returns the node tree of the document. If the document is
+// invalid the nodes up until the error are returned.
+func (d *document) symbols() []documentSymbol {
+	var (
+		root   []documentSymbol
+		stack  []documentSymbol
+		starts []int
+	)
+
+	d.read(func(ev gokdl.Event, pos gokdl.Position) {
+		switch ev := ev.(type) {
+		case gokdl.StartNode:
+			name := d.nameToken(pos.Offset)
+			stack = append(stack, documentSymbol{
+				Name:           ev.Name,
+				Detail:         ev.TypeAnnotation.String(),
+				Kind:           symbolKindObject,
+				SelectionRange: d.rangeOf(name.Start, name.End),
+			})
+			starts = append(starts, pos.Offset)
+		case gokdl.EndNode:
+			n := len(stack) - 1
+			sym := stack[n]
+			sym.Range = d.rangeOf(starts[n], d.trimEnd(pos.Offset))
+			stack, starts = stack[:n], starts[:n]
+			if n == 0 {
+				root = append(root, sym)
+			} else {
+				stack[n-1].Children = append(stack[n-1].Children, sym)
+			}
+		}
+	})
+
+	if root == nil {
+		return []documentSymbol{}
+	}
+	return root
+}
+
+// foldingRanges returns the ranges of the
+// children blocks and multiline comments.
+func (d *document) foldingRanges() []foldingRange {
+	ranges := []foldingRange{}
+	var starts []int
+
+	d.read(func(ev gokdl.Event, pos gokdl.Position) {
+		switch ev := ev.(type) {
+		case gokdl.StartChildren:
+			starts = append(starts, d.position(pos.Offset).Line)
+		case gokdl.EndChildren:
+			n := len(starts) - 1
+			start, end := starts[n], d.position(pos.Offset).Line
+			starts = starts[:n]
+			// The last line is kept visible since it has the closing bracket
+			if end-1 > start {
+				ranges = append(ranges, foldingRange{StartLine: start, EndLine: end - 1})
+			}
+		case gokdl.Comment:
+			start := d.position(pos.Offset).Line
+			end := d.position(pos.Offset + len(ev.Text)).Line
+			if end > start {
+				ranges = append(ranges, foldingRange{StartLine: start, EndLine: end, Kind: "comment"})
+			}
+		}
+	})
+
+	return ranges
+}
+
+// read calls fn with each event of the document until the end or an error.
+func (d *document) read(fn func(gokdl.Event, gokdl.Position)) {
+	r := gokdl.NewReader(strings.NewReader(d.text))
+	defer r.Close()
+
+	for {
+		ev, err := r.Next()
+		if err != nil {
+			return
+		}
+		fn(ev, r.Pos())
+	}
+}
+
+// tokenAt returns the index of the token that contains the offset,
+// or the token that ends at the offset, or -1 if there is no such token.
+func (d *document) tokenAt(offset int) int {
+	i := sort.Search(len(d.tokens), func(i int) bool {
+		return d.tokens[i].End >= offset
+	})
+	if i == len(d.tokens) || d.tokens[i].Start > offset {
+		return -1
+	}
+	if d.tokens[i].End == offset && i+1 < len(d.tokens) && d.tokens[i+1].Start == offset {
+		// Prefer the token that starts at the offset
+		switch d.tokens[i+1].Kind {
+		case lexer.Whitespace, lexer.Newline, lexer.EOF:
+		default:
+			return i + 1
+		}
+	}
+	return i
+}
+
+// nameToken returns the token with the name of the node at offset.
+func (d *document) nameToken(offset int) lexer.Token {
+	i := d.tokenAt(offset)
+	if i < 0 {
+		return lexer.Token{Start: offset, End: offset}
+	}
+	if d.tokens[i].Kind == lexer.Annotation && i+1 < len(d.tokens) {
+		i++
+	}
+	return d.tokens[i]
+}
+
+// hover returns the type annotation of the node,
+// property or value at the offset, if any.
+func (d *document) hover(offset int) *hover {
+	i := d.tokenAt(offset)
+	if i < 0 {
+		return nil
+	}
+
+	tok := d.tokens[i]
+	var annot lexer.Token
+	switch tok.Kind {
+	case lexer.Annotation:
+		annot = tok
+		if i+1 < len(d.tokens) {
+			tok = d.tokens[i+1]
+		}
+	case lexer.Identifier, lexer.String, lexer.RawString, lexer.Number, lexer.Keyword:
+		if i == 0 || d.tokens[i-1].Kind != lexer.Annotation {
+			return nil
+		}
+		annot = d.tokens[i-1]
+	default:
+		return nil
+	}
+
+	name := strings.TrimSpace(annot.Text[1 : len(annot.Text)-1])
+	if len(name) > 1 && name[0] == '"' {
+		name = name[1 : len(name)-1]
+	}
+
+	value := fmt.Sprintf("Type annotation `%s`", name)
+	if desc, ok := annotationDescriptions[name]; ok {
+		value += ": " + desc
+	}
+
+	rng := d.rangeOf(annot.Start, tok.End)
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: value},
+		Range:    &rng,
+	}
+}
+
+var annotationDescriptions = map[string]string{
+	gokdl.I8.String():  "8-bit signed integer",
+	gokdl.I16.String(): "16-bit signed integer",
+	gokdl.I32.String(): "32-bit signed integer",
+	gokdl.I64.String(): "64-bit signed integer",
+	gokdl.U8.String():  "8-bit unsigned integer",
+	gokdl.U16.String(): "16-bit unsigned integer",
+	gokdl.U32.String(): "32-bit unsigned integer",
+	gokdl.U64.String(): "64-bit unsigned integer",
+	gokdl.F32.String(): "32-bit floating point number",
+	gokdl.F64.String(): "64-bit floating point number",
+}
+
+// completionContext returns the names of the parent nodes at the offset
+// and, if the offset is after the name of a node, the name of that node.
+// The word being written at the offset is not considered.
+func (d *document) completionContext(offset int) (parents []string, node string) {
+	escaped := false
+	for _, tok := range d.tokens {
+		if tok.End > offset || tok.End == offset && isWord(tok.Kind) {
+			break
+		}
+
+		switch tok.Kind {
+		case lexer.Newline:
+			if !escaped {
+				node = ""
+			}
+			escaped = false
+		case lexer.Punctuation:
+			escaped = tok.Text == `\`
+			switch tok.Text {
+			case ";":
+				node = ""
+			case "{":
+				parents = append(parents, node)
+				node = ""
+			case "}":
+				if len(parents) > 0 {
+					parents = parents[:len(parents)-1]
+				}
+				node = ""
+			}
+		case lexer.Identifier, lexer.String, lexer.RawString:
+			if node == "" {
+				node = unquote(tok)
+			}
+		}
+	}
+	return parents, node
+}
+
+func isWord(k lexer.Kind) bool {
+	switch k {
+	case lexer.Identifier, lexer.String, lexer.RawString, lexer.Number, lexer.Keyword:
+		return true
+	}
+	return false
+}
+
+// unquote returns the name in an identifier or string token.
+func unquote(tok lexer.Token) string {
+	doc, err := gokdl.ParseString(tok.Text)
+	if err != nil || len(doc.Nodes()) != 1 {
+		return tok.Text
+	}
+	return doc.Nodes()[0].Name
+}
+
+// utf16Len returns the number of UTF-16 code units of r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
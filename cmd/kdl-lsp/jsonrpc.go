@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC error codes used by the server.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeRequestFailed  = -32803
+)
+
+// message is a JSON-RPC 2.0 request, response or notification.
+// Notifications have no ID and responses have no method.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes messages using the base protocol
+// of LSP, i.e. with a Content-Length header.
+type conn struct {
+	r  *textproto.Reader
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		r: textproto.NewReader(bufio.NewReader(r)),
+		w: w,
+	}
+}
+
+// maxContentLength is the largest body that is read.
+const maxContentLength = 64 << 20
+
+// read returns the next message. Messages that are invalid, e.g.
+// because of a malformed header, are reported as a *responseError
+// so that the caller can continue with the next message.
+func (c *conn) read() (*message, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		var perr textproto.ProtocolError
+		if errors.As(err, &perr) {
+			// Skip the rest of the header to continue with the next message.
+			for {
+				line, err := c.r.ReadLine()
+				if err != nil {
+					return nil, err
+				}
+				if line == "" {
+					break
+				}
+			}
+			return nil, &responseError{Code: codeParseError, Message: perr.Error()}
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, &responseError{
+			Code:    codeParseError,
+			Message: fmt.Sprintf("invalid Content-Length: %q", header.Get("Content-Length")),
+		}
+	}
+	if length > maxContentLength {
+		// Skip the body to continue with the next message.
+		if _, err := io.CopyN(io.Discard, c.r.R, int64(length)); err != nil {
+			return nil, err
+		}
+		return nil, &responseError{
+			Code:    codeParseError,
+			Message: fmt.Sprintf("Content-Length %d exceeds the maximum of %d", length, maxContentLength),
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &responseError{Code: codeParseError, Message: err.Error()}
+	}
+	return &msg, nil
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+func (c *conn) notify(method string, params any) error {
+	bs, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: bs})
+}
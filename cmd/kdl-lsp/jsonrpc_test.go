@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnReadInvalid(t *testing.T) {
+	tests := []struct {
+		testname string
+		input    string
+	}{
+		{"missing length", "Content-Type: text/plain\r\n\r\n"},
+		{"invalid length", "Content-Length: abc\r\n\r\n"},
+		{"negative length", "Content-Length: -1\r\n\r\n"},
+		{"too large length", fmt.Sprintf("Content-Length: %d\r\n\r\n%s", maxContentLength+1, strings.Repeat(" ", maxContentLength+1))},
+		{"malformed header", "Content-Length\r\n\r\n"},
+		{"invalid body", "Content-Length: 1\r\n\r\n{"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Arrange
+			input := test.input + "Content-Length: 17\r\n\r\n{\"method\":\"next\"}"
+			c := newConn(strings.NewReader(input), io.Discard)
+
+			// Act
+			_, err := c.read()
+			next, nextErr := c.read()
+
+			// Assert
+			var rerr *responseError
+			require.ErrorAs(t, err, &rerr)
+			require.Equal(t, codeParseError, rerr.Code)
+			require.NoError(t, nextErr)
+			require.Equal(t, "next", next.Method)
+		})
+	}
+}
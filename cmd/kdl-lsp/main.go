@@ -0,0 +1,55 @@
+// Command kdl-lsp is a language server for KDL documents.
+//
+// The server communicates over stdin and stdout using the
+// Language Server Protocol and provides diagnostics, document
+// symbols, formatting, folding ranges and hover information.
+// Node and property names are completed if a KDL Schema document
+// is configured, either with the -schema flag or with the
+// "schema" initialization option.
+//
+// Usage:
+//
+//	kdl-lsp [-schema path] [-log path]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/lunjon/gokdl/schema"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "", "path to a KDL Schema document used for completion")
+	logPath := flag.String("log", "", "path to a file to write logs to")
+	flag.Parse()
+
+	var logOutput io.Writer = io.Discard
+	if *logPath != "" {
+		f, err := os.OpenFile(*logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		logOutput = f
+	}
+
+	s := newServer(os.Stdin, os.Stdout, log.New(logOutput, "", log.LstdFlags))
+	if *schemaPath != "" {
+		sch, err := schema.ParseFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		s.schema = sch
+	}
+
+	if err := s.serve(); err != nil {
+		s.logger.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/lunjon/gokdl/schema"
+)
+
+// lookup returns the nodes of the schema s that can appear as
+// children of the node at path. If the path is not described
+// by the schema, all nodes of the schema are returned.
+func lookup(s *schema.Schema, path []string) []*schema.Node {
+	nodes := s.Nodes
+	for _, name := range path {
+		var found *schema.Node
+		for _, n := range nodes {
+			if n.Name == name {
+				found = n
+				break
+			}
+		}
+		if found == nil {
+			return allNodes(s)
+		}
+		nodes = found.Children
+	}
+	return nodes
+}
+
+// allNodes returns every node of the schema s, once for each name.
+func allNodes(s *schema.Schema) []*schema.Node {
+	seen := map[string]bool{}
+	var nodes []*schema.Node
+	var walk func([]*schema.Node)
+	walk = func(ns []*schema.Node) {
+		for _, n := range ns {
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				nodes = append(nodes, n)
+			}
+			walk(n.Children)
+		}
+	}
+	walk(s.Nodes)
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+// complete returns the completion items of the schema s for the
+// context at the cursor: the names of the nodes allowed in parents,
+// or the properties of node if the cursor is after the name of a node.
+func complete(s *schema.Schema, parents []string, node string) []completionItem {
+	items := []completionItem{}
+	if node == "" {
+		for _, n := range lookup(s, parents) {
+			items = append(items, completionItem{
+				Label:  n.Name,
+				Kind:   completionKindClass,
+				Detail: n.Description,
+			})
+		}
+		return items
+	}
+
+	for _, n := range lookup(s, parents) {
+		if n.Name != node {
+			continue
+		}
+		for _, p := range n.Props {
+			items = append(items, completionItem{
+				Label:      p.Name,
+				Kind:       completionKindProperty,
+				Detail:     p.Description,
+				InsertText: p.Name + "=",
+			})
+		}
+	}
+	return items
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/lunjon/gokdl/format"
+	"github.com/lunjon/gokdl/schema"
+)
+
+// errExit is returned by serve when the exit notification is received.
+var errExit = errors.New("exit")
+
+type server struct {
+	conn     *conn
+	logger   *log.Logger
+	schema   *schema.Schema
+	docs     map[string]*document
+	shutdown bool
+}
+
+func newServer(r io.Reader, w io.Writer, logger *log.Logger) *server {
+	return &server{
+		conn:   newConn(r, w),
+		logger: logger,
+		docs:   map[string]*document{},
+	}
+}
+
+// serve handles messages until the exit notification is received
+// or the input is closed. It returns nil if the server was
+// shut down before exiting.
+func (s *server) serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			var rerr *responseError
+			if errors.As(err, &rerr) {
+				s.logger.Printf("invalid message: %s", err)
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExit
+			}
+			return nil
+		}
+
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			if err != nil {
+				s.logger.Printf("%s: %s", msg.Method, err)
+			}
+			continue
+		}
+
+		resp := &message{ID: msg.ID, Result: result}
+		if err != nil {
+			var rerr *responseError
+			if !errors.As(err, &rerr) {
+				rerr = &responseError{Code: codeRequestFailed, Message: err.Error()}
+			}
+			resp.Result = nil
+			resp.Error = rerr
+		} else if result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+
+		if err := s.conn.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params initializeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params)
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+	case "textDocument/documentSymbol":
+		doc, err := s.document(msg)
+		if err != nil {
+			return nil, err
+		}
+		return doc.symbols(), nil
+	case "textDocument/foldingRange":
+		doc, err := s.document(msg)
+		if err != nil {
+			return nil, err
+		}
+		return doc.foldingRanges(), nil
+	case "textDocument/formatting":
+		doc, err := s.document(msg)
+		if err != nil {
+			return nil, err
+		}
+		return formatDocument(doc)
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.lookup(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if h := doc.hover(doc.offset(params.Position)); h != nil {
+			return h, nil
+		}
+		return nil, nil
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.lookup(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if s.schema == nil {
+			return []completionItem{}, nil
+		}
+		parents, node := doc.completionContext(doc.offset(params.Position))
+		return complete(s.schema, parents, node), nil
+	}
+
+	if strings.HasPrefix(msg.Method, "$/") {
+		// Optional notifications and requests can be ignored
+		return nil, nil
+	}
+	return nil, &responseError{
+		Code:    codeMethodNotFound,
+		Message: fmt.Sprintf("method not found: %s", msg.Method),
+	}
+}
+
+func (s *server) initialize(params initializeParams) (any, error) {
+	if path := params.InitializationOptions.Schema; path != "" {
+		sch, err := schema.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s.schema = sch
+	}
+
+	caps := serverCapabilities{
+		TextDocumentSync:           syncFull,
+		DocumentSymbolProvider:     true,
+		DocumentFormattingProvider: true,
+		FoldingRangeProvider:       true,
+		HoverProvider:              true,
+	}
+	if s.schema != nil {
+		caps.CompletionProvider = &completionOptions{}
+	}
+
+	return initializeResult{
+		Capabilities: caps,
+		ServerInfo:   serverInfo{Name: "kdl-lsp"},
+	}, nil
+}
+
+// update sets the text of the document
+// and publishes the diagnostics of it.
+func (s *server) update(uri, text string) error {
+	doc := newDocument(text)
+	s.docs[uri] = doc
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+func (s *server) document(msg *message) (*document, error) {
+	var params documentParams
+	if err := unmarshalParams(msg, &params); err != nil {
+		return nil, err
+	}
+	return s.lookup(params.TextDocument.URI)
+}
+
+func (s *server) lookup(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{
+			Code:    codeInvalidParams,
+			Message: fmt.Sprintf("document not open: %s", uri),
+		}
+	}
+	return doc, nil
+}
+
+// formatDocument returns the edit that replaces the
+// whole document with the formatted document.
+func formatDocument(doc *document) ([]textEdit, error) {
+	text, err := format.Source([]byte(doc.text))
+	if err != nil {
+		return nil, err
+	}
+
+	if string(text) == doc.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{
+		Range:   doc.rangeOf(0, len(doc.text)),
+		NewText: string(text),
+	}}, nil
+}
+
+func unmarshalParams(msg *message, v any) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
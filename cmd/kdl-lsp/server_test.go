@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// client is a minimal LSP client connected to a server running
+// in a separate goroutine. Notifications sent by the server are
+// kept until read by the test.
+type client struct {
+	t             *testing.T
+	conn          *conn
+	nextID        int
+	notifications []message
+	done          chan error
+}
+
+func newClient(t *testing.T, options map[string]any) *client {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	s := newServer(serverIn, serverOut, log.New(io.Discard, "", 0))
+
+	c := &client{
+		t:    t,
+		conn: newConn(clientIn, clientOut),
+		done: make(chan error, 1),
+	}
+	go func() {
+		c.done <- s.serve()
+		serverOut.Close()
+	}()
+	t.Cleanup(func() {
+		clientOut.Close()
+		clientIn.Close()
+	})
+
+	c.request("initialize", map[string]any{"initializationOptions": options}, nil)
+	c.notify("initialized", struct{}{})
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	require.NoError(c.t, c.conn.notify(method, params))
+}
+
+// request sends a request and decodes the result of the response into
+// result. Notifications received before the response are kept.
+func (c *client) request(method string, params any, result any) *responseError {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	bs, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(&message{ID: &id, Method: method, Params: bs}))
+
+	for {
+		msg := c.read()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, *msg)
+			continue
+		}
+
+		require.JSONEq(c.t, string(id), string(*msg.ID))
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			bs, err := json.Marshal(msg.Result)
+			require.NoError(c.t, err)
+			require.NoError(c.t, json.Unmarshal(bs, result))
+		}
+		return nil
+	}
+}
+
+// diagnostics returns the diagnostics of the next
+// publishDiagnostics notification sent by the server.
+func (c *client) diagnostics() publishDiagnosticsParams {
+	c.t.Helper()
+
+	var msg message
+	if len(c.notifications) > 0 {
+		msg, c.notifications = c.notifications[0], c.notifications[1:]
+	} else {
+		msg = *c.read()
+	}
+	require.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+
+	var params publishDiagnosticsParams
+	require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+	return params
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+
+	type result struct {
+		msg *message
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		msg, err := c.conn.read()
+		ch <- result{msg, err}
+	}()
+
+	select {
+	case r := <-ch:
+		require.NoError(c.t, r.err)
+		return r.msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timeout waiting for message from server")
+		return nil
+	}
+}
+
+func (c *client) open(uri, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", didOpenParams{
+		TextDocument: textDocumentItem{URI: uri, Text: text, Version: 1},
+	})
+}
+
+func at(uri string, line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Position:     position{Line: line, Character: character},
+	}
+}
+
+func doc(uri string) documentParams {
+	return documentParams{TextDocument: textDocumentIdentifier{URI: uri}}
+}
+
+func rng(startLine, startChar, endLine, endChar int) lspRange {
+	return lspRange{
+		Start: position{Line: startLine, Character: startChar},
+		End:   position{Line: endLine, Character: endChar},
+	}
+}
+
+const uri = "file:///test.kdl"
+
+func TestServerInitialize(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+	c.nextID = 10
+
+	// Act
+	var result initializeResult
+	err := c.request("initialize", struct{}{}, &result)
+
+	// Assert
+	require.Nil(t, err)
+	require.Equal(t, syncFull, result.Capabilities.TextDocumentSync)
+	require.True(t, result.Capabilities.DocumentSymbolProvider)
+	require.True(t, result.Capabilities.DocumentFormattingProvider)
+	require.True(t, result.Capabilities.FoldingRangeProvider)
+	require.True(t, result.Capabilities.HoverProvider)
+	require.Nil(t, result.Capabilities.CompletionProvider)
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+
+	// Act
+	c.open(uri, "node 1\nother (u8)\"value\"\n")
+	invalid := c.diagnostics()
+
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   textDocumentIdentifier{URI: uri},
+		"contentChanges": []map[string]string{{"text": "node 1\nother \"terminated\"\n"}},
+	})
+	valid := c.diagnostics()
+
+	// Assert
+	require.Equal(t, uri, invalid.URI)
+	require.Len(t, invalid.Diagnostics, 1)
+	require.Equal(t, severityError, invalid.Diagnostics[0].Severity)
+	require.Equal(t, 1, invalid.Diagnostics[0].Range.Start.Line)
+	require.NotEmpty(t, invalid.Diagnostics[0].Message)
+
+	require.Empty(t, valid.Diagnostics)
+}
+
+func TestServerDiagnosticsShadowedProps(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+
+	// Act
+	c.open(uri, "node a=1 b=2 (x)a=3 a=4\nother a=1\n")
+	params := c.diagnostics()
+
+	// Assert
+	require.Equal(t, []diagnostic{
+		{
+			Range:    rng(0, 5, 0, 6),
+			Severity: severityWarning,
+			Source:   "kdl",
+			Message:  "property a is shadowed by a later property with the same name",
+		},
+		{
+			Range:    rng(0, 16, 0, 17),
+			Severity: severityWarning,
+			Source:   "kdl",
+			Message:  "property a is shadowed by a later property with the same name",
+		},
+	}, params.Diagnostics)
+}
+
+func TestServerDocumentSymbols(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+	c.open(uri, `(author)parent "arg" {
+    child
+    "other child" key=1; last
+}
+sibling
+`)
+	c.diagnostics()
+
+	// Act
+	var symbols []documentSymbol
+	err := c.request("textDocument/documentSymbol", doc(uri), &symbols)
+
+	// Assert
+	require.Nil(t, err)
+	require.Equal(t, []documentSymbol{
+		{
+			Name:           "parent",
+			Detail:         "author",
+			Kind:           symbolKindObject,
+			Range:          rng(0, 0, 3, 1),
+			SelectionRange: rng(0, 8, 0, 14),
+			Children: []documentSymbol{
+				{Name: "child", Kind: symbolKindObject, Range: rng(1, 4, 1, 9), SelectionRange: rng(1, 4, 1, 9)},
+				{Name: "other child", Kind: symbolKindObject, Range: rng(2, 4, 2, 23), SelectionRange: rng(2, 4, 2, 17)},
+				{Name: "last", Kind: symbolKindObject, Range: rng(2, 25, 2, 29), SelectionRange: rng(2, 25, 2, 29)},
+			},
+		},
+		{Name: "sibling", Kind: symbolKindObject, Range: rng(4, 0, 4, 7), SelectionRange: rng(4, 0, 4, 7)},
+	}, symbols)
+}
+
+func TestServerFoldingRanges(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+	c.open(uri, `/*
+ comment
+*/
+parent {
+    child {
+        grandchild
+    }
+    single { line; }
+}
+`)
+	c.diagnostics()
+
+	// Act
+	var ranges []foldingRange
+	err := c.request("textDocument/foldingRange", doc(uri), &ranges)
+
+	// Assert
+	require.Nil(t, err)
+	require.ElementsMatch(t, []foldingRange{
+		{StartLine: 0, EndLine: 2, Kind: "comment"},
+		{StartLine: 3, EndLine: 7},
+		{StartLine: 4, EndLine: 5},
+	}, ranges)
+}
+
+func TestServerHover(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+	c.open(uri, `(author)node (u8)255 ("my type")key=(f64)1.5 plain`)
+	c.diagnostics()
+
+	tests := []struct {
+		character int
+		expected  string
+	}{
+		{1, "Type annotation `author`"},
+		{9, "Type annotation `author`"},
+		{18, "Type annotation `u8`: 8-bit unsigned integer"},
+		{32, "Type annotation `my type`"},
+		{41, "Type annotation `f64`: 64-bit floating point number"},
+		{46, ""},
+	}
+
+	for _, test := range tests {
+		// Act
+		var h *hover
+		err := c.request("textDocument/hover", at(uri, 0, test.character), &h)
+
+		// Assert
+		require.Nil(t, err)
+		if test.expected == "" {
+			require.Nil(t, h, "character %d", test.character)
+		} else {
+			require.NotNil(t, h, "character %d", test.character)
+			require.Equal(t, test.expected, h.Contents.Value)
+		}
+	}
+}
+
+func TestServerFormatting(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+	c.open(uri, "node   1 \\\n  key=\"value\" {child;}\n")
+	c.diagnostics()
+
+	// Act
+	var edits []textEdit
+	err := c.request("textDocument/formatting", doc(uri), &edits)
+
+	// Assert
+	require.Nil(t, err)
+	require.Equal(t, []textEdit{{
+		Range:   rng(0, 0, 2, 0),
+		NewText: "node 1 key=\"value\" {\n    child\n}\n",
+	}}, edits)
+}
+
+func TestServerFormattingComments(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+	c.open(uri, "// comment\nnode   1 // trailing\n")
+	c.diagnostics()
+
+	// Act
+	var edits []textEdit
+	err := c.request("textDocument/formatting", doc(uri), &edits)
+
+	// Assert
+	require.Nil(t, err)
+	require.Equal(t, []textEdit{{
+		Range:   rng(0, 0, 2, 0),
+		NewText: "// comment\nnode 1 // trailing\n",
+	}}, edits)
+}
+
+func TestServerFormattingInvalid(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+	c.open(uri, "node {\n")
+	c.diagnostics()
+
+	// Act
+	err := c.request("textDocument/formatting", doc(uri), nil)
+
+	// Assert
+	require.NotNil(t, err)
+}
+
+func TestServerCompletion(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "schema.kdl")
+	require.NoError(t, os.WriteFile(path, []byte(`document {
+    node "package" description="A package" {
+        prop "name" description="Name of the package"
+        prop "version"
+        children {
+            node "dependency" {
+                prop "optional"
+            }
+        }
+    }
+    node "workspace"
+}`), 0o644))
+
+	c := newClient(t, map[string]any{"schema": path})
+	c.open(uri, `pa
+package n
+package {
+    d
+    dependency 
+}
+`)
+	c.diagnostics()
+
+	labels := func(items []completionItem) []string {
+		var ls []string
+		for _, item := range items {
+			ls = append(ls, item.Label)
+		}
+		return ls
+	}
+
+	tests := []struct {
+		line, character int
+		expected        []string
+	}{
+		{0, 2, []string{"package", "workspace"}},
+		{1, 9, []string{"name", "version"}},
+		{3, 5, []string{"dependency"}},
+		{4, 15, []string{"optional"}},
+	}
+
+	for _, test := range tests {
+		// Act
+		var items []completionItem
+		err := c.request("textDocument/completion", at(uri, test.line, test.character), &items)
+
+		// Assert
+		require.Nil(t, err)
+		require.Equal(t, test.expected, labels(items), "%d:%d", test.line, test.character)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+
+	// Act
+	err := c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+
+	// Assert
+	require.Nil(t, err)
+	select {
+	case err := <-c.done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not exit")
+	}
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+
+	// Act
+	c.notify("exit", nil)
+
+	// Assert
+	select {
+	case err := <-c.done:
+		require.ErrorIs(t, err, errExit)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not exit")
+	}
+}
+
+func TestServerInvalidMessage(t *testing.T) {
+	// Arrange
+	c := newClient(t, nil)
+
+	// Act
+	_, err := io.WriteString(c.conn.w, "Content-Length: -1\r\n\r\n")
+	require.NoError(t, err)
+	rerr := c.request("shutdown", nil, nil)
+
+	// Assert
+	require.Nil(t, rerr)
+}
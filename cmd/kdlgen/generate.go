@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lunjon/gokdl/schema"
+)
+
+type options struct {
+	pkg string
+	// The name of the struct of the document.
+	typeName string
+	// Generate Validate methods.
+	validate bool
+}
+
+// generator generates the structs of the nodes of a schema.
+type generator struct {
+	opts    options
+	structs []*goStruct
+	// The names of the types and variables that are declared.
+	names   map[string]bool
+	imports map[string]bool
+	// The patterns of the validations, by the name of their variable.
+	patterns map[string]string
+}
+
+// goStruct is the struct of a node.
+type goStruct struct {
+	name string
+	// The path of the node, e.g. "server.tls",
+	// or empty if the struct is of the document.
+	path        string
+	description string
+	fields      []*goField
+}
+
+type goField struct {
+	name        string
+	typ         string
+	tag         string
+	description string
+	// What the field is decoded from in errors, e.g. `server: property "port"`.
+	item string
+	// The validations of the value of the field, if it is one.
+	value *schema.Value
+	// The node, if the field is decoded from child nodes.
+	node *schema.Node
+	// The struct of the node, if it is one.
+	elem    string
+	slice   bool
+	pointer bool
+}
+
+// generate returns the Go source of the structs of the schema s.
+func generate(s *schema.Schema, opts options) ([]byte, error) {
+	g := &generator{
+		opts:     opts,
+		names:    map[string]bool{},
+		imports:  map[string]bool{},
+		patterns: map[string]string{},
+	}
+	g.structType(opts.typeName, "", &schema.Node{Children: s.Nodes})
+
+	var methods bytes.Buffer
+	if opts.validate {
+		for _, st := range g.structs {
+			g.validateMethod(&methods, st)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by kdlgen. DO NOT EDIT.\n\npackage %s\n", opts.pkg)
+	if len(g.imports) > 0 {
+		buf.WriteString("\nimport (\n")
+		for _, imp := range sortedKeys(g.imports) {
+			fmt.Fprintf(&buf, "\t%q\n", imp)
+		}
+		buf.WriteString(")\n")
+	}
+	if len(g.patterns) > 0 {
+		buf.WriteString("\nvar (\n")
+		for _, name := range sortedKeys(g.patterns) {
+			fmt.Fprintf(&buf, "\t%s = regexp.MustCompile(%s)\n", name, strconv.Quote(g.patterns[name]))
+		}
+		buf.WriteString(")\n")
+	}
+
+	for _, st := range g.structs {
+		buf.WriteString("\n")
+		if st.path == "" {
+			fmt.Fprintf(&buf, "// %s is decoded from the document.\n", st.name)
+		} else {
+			fmt.Fprintf(&buf, "// %s is decoded from the %q node.\n", st.name, st.path)
+		}
+		if st.description != "" {
+			fmt.Fprintf(&buf, "//\n%s", comment(st.description))
+		}
+		fmt.Fprintf(&buf, "type %s struct {\n", st.name)
+		for _, f := range st.fields {
+			buf.WriteString(comment(f.description))
+			fmt.Fprintf(&buf, "%s %s %s\n", f.name, f.typ, structTag(f.tag))
+		}
+		buf.WriteString("}\n")
+	}
+	buf.Write(methods.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %w", err)
+	}
+	return src, nil
+}
+
+// structType adds the struct of the node n at path, and returns its name.
+func (g *generator) structType(name, path string, n *schema.Node) string {
+	st := &goStruct{name: g.unique(name), path: path, description: n.Description}
+	g.structs = append(g.structs, st)
+	fields := map[string]bool{}
+	field := func(f *goField) {
+		f.name = uniqueName(f.name, fields)
+		st.fields = append(st.fields, f)
+	}
+
+	for i, a := range n.Args {
+		f := &goField{
+			name:        argName(i, len(n.Args), a.Repeated),
+			typ:         valueType(a.Value),
+			description: a.Description,
+			item:        itemPath(path, fmt.Sprintf("argument %d", i+1)),
+			value:       &a.Value,
+			slice:       a.Repeated,
+		}
+		f.tag = ",arg"
+		if a.Repeated {
+			// The bounds of the args kind are of the number of arguments
+			f.tag = ",args" + required(!a.Optional) + constraints(valueOnly(a.Value), f.typ)
+			f.typ = "[]" + f.typ
+		} else {
+			f.tag += required(!a.Optional) + constraints(a.Value, f.typ)
+			if a.Optional {
+				optional(f)
+			}
+		}
+		field(f)
+	}
+
+	for _, p := range n.Props {
+		typ := valueType(p.Value)
+		f := &goField{
+			name:        goName(p.Name),
+			typ:         typ,
+			tag:         p.Name + ",prop" + required(p.Required) + constraints(p.Value, typ),
+			description: p.Description,
+			item:        itemPath(path, fmt.Sprintf("property %q", p.Name)),
+			value:       &p.Value,
+		}
+		if !p.Required {
+			optional(f)
+		}
+		field(f)
+	}
+
+	for _, c := range n.Children {
+		f := &goField{
+			name:        goName(c.Name),
+			description: c.Description,
+			item:        joinPath(path, c.Name),
+			node:        c,
+		}
+		var valueTag string
+		if isValueNode(c) {
+			f.typ = valueType(c.Args[0].Value)
+			f.value = &c.Args[0].Value
+			valueTag = constraints(c.Args[0].Value, f.typ)
+		} else {
+			typeName := goName(c.Name)
+			if path != "" {
+				typeName = st.name + typeName
+			}
+			f.elem = g.structType(typeName, f.item, c)
+			f.typ = f.elem
+		}
+
+		switch {
+		case c.Max != 1:
+			f.slice = true
+			f.typ = "[]" + f.typ
+			// The bounds are of the values of the nodes, but
+			// apply to the number of them if it is a slice
+			if f.value != nil {
+				valueTag = constraints(valueOnly(*f.value), f.typ)
+			}
+		case c.Min == 0:
+			optional(f)
+		}
+		f.tag = c.Name + required(c.Min > 0) + valueTag
+		field(f)
+	}
+	return st.name
+}
+
+// optional makes the field f, of a value or node that is optional,
+// a pointer, so that it is nil if the item is absent. An any is
+// already nil then.
+func optional(f *goField) {
+	if f.typ != "any" {
+		f.pointer = true
+		f.typ = "*" + f.typ
+	}
+}
+
+// validateMethod writes the Validate method of the struct st to w.
+func (g *generator) validateMethod(w *bytes.Buffer, st *goStruct) {
+	g.imports["errors"] = true
+	what := "document"
+	if st.path != "" {
+		what = fmt.Sprintf("%q node", st.path)
+	}
+	fmt.Fprintf(w, "\n// Validate returns an error for each value of the %s that the schema does not allow.\n", what)
+	fmt.Fprintf(w, "func (v *%s) Validate() error {\nvar errs []error\n", st.name)
+
+	for _, f := range st.fields {
+		x := "v." + f.name
+		if f.value != nil && f.slice {
+			var checks bytes.Buffer
+			g.validateValue(&checks, st, f, "x")
+			if checks.Len() > 0 {
+				fmt.Fprintf(w, "for _, x := range %s {\n%s}\n", x, checks.Bytes())
+			}
+		} else if f.value != nil && f.pointer {
+			// An absent value is not validated
+			var checks bytes.Buffer
+			g.validateValue(&checks, st, f, "*"+x)
+			if checks.Len() > 0 {
+				fmt.Fprintf(w, "if %s != nil {\n%s}\n", x, checks.Bytes())
+			}
+		} else if f.value != nil {
+			g.validateValue(w, st, f, x)
+		}
+		if f.node == nil {
+			continue
+		}
+
+		if f.slice {
+			if f.node.Min > 1 {
+				g.check(w, fmt.Sprintf("len(%s) < %d", x, f.node.Min), f.item, fmt.Sprintf("number of nodes %%d is less than the minimum %d", f.node.Min), "len("+x+")")
+			}
+			if f.node.Max > 1 {
+				g.check(w, fmt.Sprintf("len(%s) > %d", x, f.node.Max), f.item, fmt.Sprintf("number of nodes %%d is greater than the maximum %d", f.node.Max), "len("+x+")")
+			}
+		}
+		if f.elem == "" {
+			continue
+		}
+		switch {
+		case f.slice:
+			fmt.Fprintf(w, "for i := range %s {\nif err := %s[i].Validate(); err != nil {\nerrs = append(errs, err)\n}\n}\n", x, x)
+		case f.pointer:
+			fmt.Fprintf(w, "if %s != nil {\nif err := %s.Validate(); err != nil {\nerrs = append(errs, err)\n}\n}\n", x, x)
+		default:
+			fmt.Fprintf(w, "if err := %s.Validate(); err != nil {\nerrs = append(errs, err)\n}\n", x)
+		}
+	}
+	w.WriteString("return errors.Join(errs...)\n}\n")
+}
+
+// validateValue writes the validations of the value x of the field f.
+func (g *generator) validateValue(w *bytes.Buffer, st *goStruct, f *goField, x string) {
+	typ := strings.TrimLeft(f.typ, "[]*")
+	v := f.value
+	if isNumber(typ) {
+		// A bound that the type can not represent does not compile
+		if v.Min != nil && representable(*v.Min, typ) {
+			min := formatBound(*v.Min)
+			g.check(w, fmt.Sprintf("%s < %s", x, min), f.item, "value %v is less than the minimum "+min, x)
+		}
+		if v.Max != nil && representable(*v.Max, typ) {
+			max := formatBound(*v.Max)
+			g.check(w, fmt.Sprintf("%s > %s", x, max), f.item, "value %v is greater than the maximum "+max, x)
+		}
+	}
+
+	if len(v.Enum) > 0 {
+		var lits, names []string
+		for _, e := range v.Enum {
+			lit, ok := literal(e, typ)
+			if !ok {
+				return
+			}
+			lits = append(lits, lit)
+			names = append(names, fmt.Sprint(e))
+		}
+		verb := "%v"
+		if typ == "string" {
+			verb = "%q"
+		}
+		fmt.Fprintf(w, "switch %s {\ncase %s:\ndefault:\n", x, strings.Join(lits, ", "))
+		g.check(w, "", f.item, "value "+verb+" is not one of "+escape(strings.Join(names, ", ")), x)
+		w.WriteString("}\n")
+	}
+
+	if v.Pattern != "" && typ == "string" {
+		name := g.unique(lowerFirst(st.name + f.name + "Pattern"))
+		g.patterns[name] = v.Pattern
+		g.imports["regexp"] = true
+		g.check(w, fmt.Sprintf("!%s.MatchString(%s)", name, x), f.item, "value %q does not match the pattern "+escape(v.Pattern), x)
+	}
+}
+
+// check writes a check that adds an error if cond is true, or
+// unconditionally if it is empty. The message is a format with
+// the single argument arg, and is prefixed by the item.
+func (g *generator) check(w *bytes.Buffer, cond, item, msg, arg string) {
+	g.imports["fmt"] = true
+	format := escape(item) + ": " + msg
+	if cond == "" {
+		fmt.Fprintf(w, "errs = append(errs, fmt.Errorf(%s, %s))\n", strconv.Quote(format), arg)
+		return
+	}
+	fmt.Fprintf(w, "if %s {\nerrs = append(errs, fmt.Errorf(%s, %s))\n}\n", cond, strconv.Quote(format), arg)
+}
+
+// unique returns the name, or the name with a number
+// if it is already declared, and declares it.
+func (g *generator) unique(name string) string {
+	return uniqueName(name, g.names)
+}
+
+func uniqueName(name string, names map[string]bool) string {
+	unique := name
+	for i := 2; names[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	names[unique] = true
+	return unique
+}
+
+// isValueNode reports whether the node n is decoded
+// as a value, i.e. it only has a single argument.
+func isValueNode(n *schema.Node) bool {
+	return len(n.Args) == 1 && !n.Args[0].Optional && !n.Args[0].Repeated &&
+		len(n.Props) == 0 && len(n.Children) == 0
+}
+
+func argName(i, n int, repeated bool) string {
+	switch {
+	case n == 1 && repeated:
+		return "Values"
+	case n == 1:
+		return "Value"
+	case repeated:
+		return "Rest"
+	}
+	return fmt.Sprintf("Arg%d", i+1)
+}
+
+var numberTypes = map[string]string{
+	"i8":    "int8",
+	"i16":   "int16",
+	"i32":   "int32",
+	"i64":   "int64",
+	"u8":    "uint8",
+	"u16":   "uint16",
+	"u32":   "uint32",
+	"u64":   "uint64",
+	"isize": "int",
+	"usize": "uint",
+	"f32":   "float32",
+	"f64":   "float64",
+}
+
+// valueType returns the Go type of the value v.
+func valueType(v schema.Value) string {
+	switch v.Type {
+	case schema.TypeString:
+		return "string"
+	case schema.TypeBoolean:
+		return "bool"
+	case schema.TypeNumber:
+		if typ, ok := numberTypes[v.Format]; ok {
+			return typ
+		}
+		return "float64"
+	}
+	return "any"
+}
+
+// representable reports whether the number n is
+// a constant of the number type typ in Go.
+func representable(n float64, typ string) bool {
+	if strings.HasPrefix(typ, "float") {
+		return true
+	}
+	if n != math.Trunc(n) {
+		return false
+	}
+	switch typ {
+	case "int8":
+		return n >= math.MinInt8 && n <= math.MaxInt8
+	case "int16":
+		return n >= math.MinInt16 && n <= math.MaxInt16
+	case "int32":
+		return n >= math.MinInt32 && n <= math.MaxInt32
+	case "uint8":
+		return n >= 0 && n <= math.MaxUint8
+	case "uint16":
+		return n >= 0 && n <= math.MaxUint16
+	case "uint32":
+		return n >= 0 && n <= math.MaxUint32
+	case "uint", "uint64":
+		return n >= 0 && n < math.MaxUint64
+	}
+	return n >= math.MinInt64 && n < math.MaxInt64
+}
+
+func isNumber(typ string) bool {
+	for _, t := range numberTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// literal returns the value v as a Go literal of the type typ.
+func literal(v any, typ string) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v), typ == "string"
+	case bool:
+		return strconv.FormatBool(v), typ == "bool"
+	case int64, uint64:
+		return fmt.Sprint(v), isNumber(typ)
+	case float64:
+		return formatBound(v), strings.HasPrefix(typ, "float")
+	}
+	return "", false
+}
+
+// valueOnly returns the validations of v without the bounds,
+// for the tag of a field whose bounds are of a number of items.
+func valueOnly(v schema.Value) schema.Value {
+	v.Min, v.Max = nil, nil
+	return v
+}
+
+func required(required bool) string {
+	if required {
+		return ",required"
+	}
+	return ""
+}
+
+// constraints returns the options of the tag of a
+// field of the type typ that validate the value v.
+func constraints(v schema.Value, typ string) string {
+	var tag string
+	if isNumber(strings.TrimPrefix(typ, "[]")) {
+		if v.Min != nil {
+			tag += ",min=" + formatBound(*v.Min)
+		}
+		if v.Max != nil {
+			tag += ",max=" + formatBound(*v.Max)
+		}
+	}
+
+	if len(v.Enum) > 0 {
+		values := make([]string, len(v.Enum))
+		for i, e := range v.Enum {
+			values[i] = fmt.Sprint(e)
+			if values[i] == "" || strings.ContainsAny(values[i], " ,") {
+				// Can not be separated in the tag
+				values = nil
+				break
+			}
+		}
+		if values != nil {
+			tag += ",oneof=" + strings.Join(values, " ")
+		}
+	}
+
+	// The pattern is the rest of the tag
+	if v.Pattern != "" {
+		tag += ",pattern=" + v.Pattern
+	}
+	return tag
+}
+
+// structTag returns the struct tag with the kdl tag as a Go literal.
+func structTag(tag string) string {
+	s := "kdl:" + strconv.Quote(tag)
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// initialisms are the words that are upper case in Go names.
+var initialisms = map[string]bool{
+	"API": true, "CPU": true, "CSS": true, "DNS": true, "HTML": true,
+	"HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true,
+	"UDP": true, "URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// goName returns the exported Go name of the KDL name,
+// e.g. "MaxBody" for "max-body" and "TLS" for "tls".
+func goName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, word := range words {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(word)
+		b.WriteRune(unicode.ToUpper(r[0]))
+		b.WriteString(string(r[1:]))
+	}
+
+	s := b.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// escape returns s with the verbs of a format escaped.
+func escape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	return string(unicode.ToLower(r[0])) + string(r[1:])
+}
+
+func comment(text string) string {
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+	}
+	return b.String()
+}
+
+func formatBound(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func itemPath(path, item string) string {
+	if path == "" {
+		return item
+	}
+	return path + ": " + item
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
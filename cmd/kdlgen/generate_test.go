@@ -0,0 +1,246 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/lunjon/gokdl/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	// Arrange
+	doc, err := gokdl.ParseString(`
+document {
+    node "server" description="A server" {
+        min 1
+        value {
+            type "string"
+            pattern "^[a-z]+$"
+        }
+        prop "port" {
+            type "number"
+            format "u16"
+            required true
+            ">=" 1
+        }
+        prop "max-body" {
+            type "number"
+            format "f32"
+        }
+        children {
+            node "tls" {
+                max 1
+                prop "cert" {
+                    type "string"
+                    required true
+                }
+            }
+            node "level" {
+                max 1
+                value {
+                    enum "debug" "info"
+                }
+            }
+        }
+    }
+    node "workers" {
+        min 1
+        max 1
+        value {
+            type "number"
+            format "u8"
+        }
+    }
+}`)
+	require.NoError(t, err)
+	s, err := schema.Parse(doc)
+	require.NoError(t, err)
+
+	// Act
+	src, err := generate(s, options{pkg: "config", typeName: "Config", validate: true})
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "// Code generated by kdlgen. DO NOT EDIT.\n\n"+`package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	serverValuePattern = regexp.MustCompile("^[a-z]+$")
+)
+
+// Config is decoded from the document.
+type Config struct {
+	// A server
+	Server  []Server `+"`kdl:\"server,required\"`"+`
+	Workers uint8    `+"`kdl:\"workers,required\"`"+`
+}
+
+// Server is decoded from the "server" node.
+//
+// A server
+type Server struct {
+	Value   string     `+"`kdl:\",arg,required,pattern=^[a-z]+$\"`"+`
+	Port    uint16     `+"`kdl:\"port,prop,required,min=1\"`"+`
+	MaxBody *float32   `+"`kdl:\"max-body,prop\"`"+`
+	TLS     *ServerTLS `+"`kdl:\"tls\"`"+`
+	Level   any        `+"`kdl:\"level,oneof=debug info\"`"+`
+}
+
+// ServerTLS is decoded from the "server.tls" node.
+type ServerTLS struct {
+	Cert string `+"`kdl:\"cert,prop,required\"`"+`
+}
+
+// Validate returns an error for each value of the document that the schema does not allow.
+func (v *Config) Validate() error {
+	var errs []error
+	for i := range v.Server {
+		if err := v.Server[i].Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns an error for each value of the "server" node that the schema does not allow.
+func (v *Server) Validate() error {
+	var errs []error
+	if !serverValuePattern.MatchString(v.Value) {
+		errs = append(errs, fmt.Errorf("server: argument 1: value %q does not match the pattern ^[a-z]+$", v.Value))
+	}
+	if v.Port < 1 {
+		errs = append(errs, fmt.Errorf("server: property \"port\": value %v is less than the minimum 1", v.Port))
+	}
+	if v.TLS != nil {
+		if err := v.TLS.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns an error for each value of the "server.tls" node that the schema does not allow.
+func (v *ServerTLS) Validate() error {
+	var errs []error
+	return errors.Join(errs...)
+}
+`, string(src))
+}
+
+func TestGenerateOptional(t *testing.T) {
+	// Arrange
+	s, err := schema.ParseFile("internal/optional/schema.kdl")
+	require.NoError(t, err)
+	expected, err := os.ReadFile("internal/optional/config_gen.go")
+	require.NoError(t, err)
+
+	// Act
+	src, err := generate(s, options{pkg: "optional", typeName: "Config", validate: true})
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(src), "run go generate in internal/optional")
+}
+
+func TestGenerateNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"server", "Server"},
+		{"max-body", "MaxBody"},
+		{"tls", "TLS"},
+		{"api_url", "APIURL"},
+		{"2fa", "X2fa"},
+		{"-", "X"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, goName(test.name))
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.schema.kdl")
+	require.NoError(t, os.WriteFile(path, []byte(`document {
+    node "server" {
+        min 1
+        max 1
+        value {
+            type "string"
+        }
+        prop "port" {
+            type "number"
+            format "u16"
+            required true
+        }
+    }
+}`), 0o644))
+	output := filepath.Join(dir, "config_gen.go")
+
+	// Act
+	err := run([]string{path}, false, "", output, options{pkg: "config", typeName: "Config"})
+
+	// Assert
+	require.NoError(t, err)
+	src, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "// Code generated by kdlgen. DO NOT EDIT.\n\n"+`package config
+
+// Config is decoded from the document.
+type Config struct {
+	Server Server `+"`kdl:\"server,required\"`"+`
+}
+
+// Server is decoded from the "server" node.
+type Server struct {
+	Value string `+"`kdl:\",arg,required\"`"+`
+	Port  uint16 `+"`kdl:\"port,prop,required\"`"+`
+}
+`, string(src))
+}
+
+func TestRunInfer(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	example := filepath.Join(dir, "example.kdl")
+	require.NoError(t, os.WriteFile(example, []byte(`server "api" port=(u16)8080`), 0o644))
+	output := filepath.Join(dir, "config_gen.go")
+	schemaOutput := filepath.Join(dir, "config.schema.kdl")
+
+	// Act
+	err := run([]string{example}, true, schemaOutput, output, options{pkg: "config", typeName: "Config"})
+
+	// Assert
+	require.NoError(t, err)
+	src, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "// Code generated by kdlgen. DO NOT EDIT.\n\n"+`package config
+
+// Config is decoded from the document.
+type Config struct {
+	Server Server `+"`kdl:\"server,required\"`"+`
+}
+
+// Server is decoded from the "server" node.
+type Server struct {
+	Value string `+"`kdl:\",arg,required\"`"+`
+	Port  uint16 `+"`kdl:\"port,prop,required\"`"+`
+}
+`, string(src))
+	s, err := schema.ParseFile(schemaOutput)
+	require.NoError(t, err)
+	require.Equal(t, "server", s.Nodes[0].Name)
+}
@@ -0,0 +1,5 @@
+// Package optional is generated from schema.kdl, which has optional
+// values with validations, to test the generated code.
+package optional
+
+//go:generate go run github.com/lunjon/gokdl/cmd/kdlgen -validate -o config_gen.go schema.kdl
@@ -0,0 +1,36 @@
+package optional
+
+import (
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAbsent(t *testing.T) {
+	// Arrange
+	var c Config
+	require.NoError(t, gokdl.Unmarshal([]byte("server"), &c))
+
+	// Act
+	err := c.Validate()
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, Server{}, c.Server)
+}
+
+func TestValidatePresent(t *testing.T) {
+	// Arrange
+	name, port, level, timeout := "API", uint16(0), "warn", uint32(1)
+	c := Config{Server: Server{Value: &name, Port: &port, Level: &level, Timeout: &timeout}}
+
+	// Act
+	err := c.Validate()
+
+	// Assert
+	require.EqualError(t, err, `server: argument 1: value "API" does not match the pattern ^[a-z]+$
+server: property "port": value 0 is less than the minimum 1
+server: property "level": value "warn" is not one of debug, info
+server.timeout: value 1 is less than the minimum 5`)
+}
@@ -0,0 +1,97 @@
+// Command kdlgen generates Go structs that KDL documents are decoded
+// into with gokdl.Unmarshal.
+//
+// The structs are generated from a KDL Schema document, see the schema
+// package for the subset that is supported, or from a schema inferred
+// from example documents with -infer, which -write-schema writes to
+// a file, e.g. to review and commit it. Each node is a struct with a
+// field for each of its arguments, properties and children, with the
+// kdl tags, including required and the validations of the values.
+// Nodes with a single argument and nothing else are fields of the type
+// of the argument. Optional values and nodes are pointers, which are nil
+// if they are absent. Numbers are of the type of their format, e.g. uint8
+// for u8. With -validate, each struct also gets a Validate method that
+// reports the values that the schema does not allow, of the values
+// that are present.
+//
+// The package defaults to $GOPACKAGE, so that it can be used with
+// go:generate:
+//
+//	//go:generate go run github.com/lunjon/gokdl/cmd/kdlgen -type Config -o config_gen.go config.schema.kdl
+//
+// Usage:
+//
+//	kdlgen [-package name] [-type name] [-o path] [-validate] schema.kdl
+//	kdlgen -infer [-write-schema path] [-package name] [-type name] [-o path] [-validate] example.kdl...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lunjon/gokdl"
+	"github.com/lunjon/gokdl/schema"
+)
+
+func main() {
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "name of the package of the generated code")
+	typeName := flag.String("type", "Config", "name of the struct of the document")
+	output := flag.String("o", "", "path to write the generated code to, instead of stdout")
+	infer := flag.Bool("infer", false, "infer the schema from example documents")
+	validate := flag.Bool("validate", false, "generate Validate methods")
+	schemaOutput := flag.String("write-schema", "", "path to write the inferred schema to")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: kdlgen [flags] schema.kdl\n       kdlgen -infer [flags] example.kdl...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || (!*infer && (flag.NArg() != 1 || *schemaOutput != "")) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *pkg == "" {
+		*pkg = "main"
+	}
+
+	if err := run(flag.Args(), *infer, *schemaOutput, *output, options{pkg: *pkg, typeName: *typeName, validate: *validate}); err != nil {
+		fmt.Fprintln(os.Stderr, "kdlgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(paths []string, infer bool, schemaOutput, output string, opts options) error {
+	var s *schema.Schema
+	if infer {
+		docs := make([]gokdl.Doc, len(paths))
+		for i, path := range paths {
+			doc, err := gokdl.ParseFile(path)
+			if err != nil {
+				return err
+			}
+			docs[i] = doc
+		}
+		s = schema.Infer(docs...)
+		if schemaOutput != "" {
+			if err := os.WriteFile(schemaOutput, []byte(s.String()), 0o644); err != nil {
+				return err
+			}
+		}
+	} else {
+		var err error
+		if s, err = schema.ParseFile(paths[0]); err != nil {
+			return err
+		}
+	}
+
+	src, err := generate(s, opts)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(output, src, 0o644)
+}
@@ -0,0 +1,276 @@
+// Package config loads configuration from layered KDL documents,
+// e.g. defaults, a base file, an environment file and local overrides.
+//
+// The documents are merged in order, so that the values of later
+// documents override those of earlier ones. Nodes are matched by
+// their name and path, i.e. the names of their ancestors, and are
+// merged according to a Strategy, which can be set per path.
+// The file that each value came from is kept as an Origin.
+//
+// Paths are the names of nodes from the root separated by dots,
+// e.g. "server.tls" is the tls node among the children of the
+// server node.
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/lunjon/gokdl"
+)
+
+// Strategy is how a node is merged with the matching node,
+// i.e. the node with the same name and path, of an earlier document.
+type Strategy int
+
+const (
+	// Merge merges the nodes deeply. The properties of the later node
+	// override those of the earlier, its arguments replace those of the
+	// earlier if it has any, and the children are merged recursively.
+	Merge Strategy = iota
+	// Replace replaces the earlier node with the later node.
+	Replace
+	// AppendChildren merges the arguments and properties as Merge,
+	// but appends the children of the later node to those of the
+	// earlier instead of merging them, e.g. for a list of servers.
+	AppendChildren
+	// MergeProps merges the properties as Merge, while the
+	// arguments and children of the later node replace
+	// those of the earlier.
+	MergeProps
+)
+
+var strategyNames = map[Strategy]string{
+	Merge:          "Merge",
+	Replace:        "Replace",
+	AppendChildren: "AppendChildren",
+	MergeProps:     "MergeProps",
+}
+
+func (s Strategy) String() string {
+	return strategyNames[s]
+}
+
+// Option configures a Loader.
+type Option func(*Loader)
+
+// UseStrategy sets the strategy used to merge the nodes at path.
+// Nodes without a strategy are merged using Merge.
+func UseStrategy(path string, s Strategy) Option {
+	return func(l *Loader) {
+		l.strategies[path] = s
+	}
+}
+
+// IgnoreMissing makes the loader skip files that do
+// not exist, e.g. optional local overrides, instead
+// of returning an error.
+func IgnoreMissing() Option {
+	return func(l *Loader) {
+		l.ignoreMissing = true
+	}
+}
+
+// ResolveVariables makes the loader interpolate the references in
+// the merged document, see Interpolate, with the variables given by
+// resolve, e.g. Env.
+func ResolveVariables(resolve Resolver) Option {
+	return func(l *Loader) {
+		l.interpolate = true
+		l.resolve = resolve
+	}
+}
+
+// Loader loads and merges configuration files.
+type Loader struct {
+	strategies    map[string]Strategy
+	ignoreMissing bool
+	interpolate   bool
+	resolve       Resolver
+	validate      func(Doc) error
+	// Options of Watch.
+	notifier Notifier
+	debounce time.Duration
+	onError  func(error)
+}
+
+// NewLoader returns a loader configured by the options.
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{strategies: map[string]Strategy{}}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Load loads the files at paths and merges them in order
+// using the default strategy, Merge, for all nodes.
+func Load(paths ...string) (Doc, error) {
+	return NewLoader().Load(paths...)
+}
+
+// Load loads the files at paths and merges them in order.
+// An error is returned if any of the files can not be read
+// or parsed, with the path of the file in the message, or if
+// the merged document is not valid, see Validate.
+func (l *Loader) Load(paths ...string) (Doc, error) {
+	var doc Doc
+	for _, path := range paths {
+		d, err := gokdl.ParseFile(path)
+		if errors.Is(err, fs.ErrNotExist) && l.ignoreMissing {
+			continue
+		} else if err != nil {
+			return Doc{}, err
+		}
+		doc = l.Merge(doc, d, path)
+	}
+
+	if l.interpolate {
+		var err error
+		if doc, err = Interpolate(doc, l.resolve); err != nil {
+			return Doc{}, err
+		}
+	}
+	if l.validate != nil {
+		if err := l.validate(doc); err != nil {
+			return Doc{}, err
+		}
+	}
+	return doc, nil
+}
+
+// Merge merges the nodes of src, which came from file, into doc
+// and returns the result. Neither doc nor src is modified.
+func (l *Loader) Merge(doc Doc, src gokdl.Doc, file string) Doc {
+	nodes, origins := l.mergeScope(doc.nodes, doc.origins, src.Nodes(), "", file)
+	return Doc{nodes: nodes, origins: origins}
+}
+
+// mergeScope merges the nodes of a scope, i.e. the root or
+// the children of a node at path, and returns the result.
+//
+// Nodes are matched by name. If a name occurs more than once in
+// a scope, the nth occurrence is matched with the nth occurrence.
+func (l *Loader) mergeScope(nodes []gokdl.Node, origins []Origin, src []gokdl.Node, path, file string) ([]gokdl.Node, []Origin) {
+	nodes = append([]gokdl.Node{}, nodes...)
+	origins = append([]Origin{}, origins...)
+
+	seen := map[string]int{}
+	for _, n := range src {
+		i := nthIndex(nodes, n.Name, seen[n.Name])
+		seen[n.Name]++
+		if i < 0 {
+			nodes = append(nodes, cloneNode(n))
+			origins = append(origins, newOrigin(n, file))
+			continue
+		}
+		nodes[i], origins[i] = l.mergeNode(nodes[i], origins[i], n, join(path, n.Name), file)
+	}
+	return nodes, origins
+}
+
+// mergeNode merges src into the node dst at path,
+// with the origin o, and returns the result.
+func (l *Loader) mergeNode(dst gokdl.Node, o Origin, src gokdl.Node, path, file string) (gokdl.Node, Origin) {
+	strategy := l.strategies[path]
+	if strategy == Replace {
+		return cloneNode(src), newOrigin(src, file)
+	}
+
+	o.File = file
+	if src.TypeAnnotation != "" {
+		dst.TypeAnnotation = src.TypeAnnotation
+	}
+
+	if len(src.Args) > 0 {
+		dst.Args = append([]gokdl.Arg{}, src.Args...)
+		o.Args = file
+	} else if strategy == MergeProps {
+		dst.Args = []gokdl.Arg{}
+		o.Args = ""
+	}
+
+	dst.Props = append([]gokdl.Prop{}, dst.Props...)
+	props := make(map[string]string, len(o.Props)+len(src.Props))
+	for name, f := range o.Props {
+		props[name] = f
+	}
+	for _, p := range src.Props {
+		if i := propIndex(dst.Props, p.Name); i >= 0 {
+			dst.Props[i] = p
+		} else {
+			dst.Props = append(dst.Props, p)
+		}
+		props[p.Name] = file
+	}
+	o.Props = props
+
+	switch strategy {
+	case AppendChildren:
+		dst.Children = append(append([]gokdl.Node{}, dst.Children...), cloneNodes(src.Children)...)
+		o.Children = append(append([]Origin{}, o.Children...), newOrigins(src.Children, file)...)
+	case MergeProps:
+		dst.Children = cloneNodes(src.Children)
+		o.Children = newOrigins(src.Children, file)
+	default:
+		dst.Children, o.Children = l.mergeScope(dst.Children, o.Children, src.Children, path, file)
+	}
+	return dst, o
+}
+
+// nthIndex returns the index of the nth, starting
+// at 0, node with the name, or -1 if there is none.
+func nthIndex(nodes []gokdl.Node, name string, nth int) int {
+	for i, n := range nodes {
+		if n.Name != name {
+			continue
+		}
+		if nth == 0 {
+			return i
+		}
+		nth--
+	}
+	return -1
+}
+
+func propIndex(props []gokdl.Prop, name string) int {
+	for i, p := range props {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func split(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// cloneNode returns a deep copy of the node,
+// so that merging never modifies a parsed document.
+func cloneNode(n gokdl.Node) gokdl.Node {
+	n.Args = append([]gokdl.Arg{}, n.Args...)
+	n.Props = append([]gokdl.Prop{}, n.Props...)
+	n.Children = cloneNodes(n.Children)
+	return n
+}
+
+func cloneNodes(nodes []gokdl.Node) []gokdl.Node {
+	clones := make([]gokdl.Node, len(nodes))
+	for i, n := range nodes {
+		clones[i] = cloneNode(n)
+	}
+	return clones
+}
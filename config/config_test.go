@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMerge(t *testing.T) {
+	// Arrange
+	base := writeFile(t, "base.kdl", `
+server "localhost" port=8080 timeout=30 {
+    tls enabled=false
+}
+database url="postgres://localhost"`)
+	env := writeFile(t, "prod.kdl", `
+server "example.com" port=443 {
+    tls enabled=true cert="cert.pem"
+    (ms)cache 100
+}
+logging level="warn"`)
+
+	// Act
+	doc, err := Load(base, env)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, `server "example.com" port=443 timeout=30 {
+    tls enabled=true cert="cert.pem"
+    (ms)cache 100
+}
+database url="postgres://localhost"
+logging level="warn"
+`, doc.KDL().String())
+}
+
+func TestLoadOrigins(t *testing.T) {
+	// Arrange
+	base := writeFile(t, "base.kdl", `server "localhost" port=8080 timeout=30 { tls enabled=false; }`)
+	env := writeFile(t, "prod.kdl", `server port=443 { tls cert="cert.pem"; cache 100; }`)
+
+	// Act
+	doc, err := Load(base, env)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, []Origin{{
+		File:  env,
+		Args:  base,
+		Props: map[string]string{"port": env, "timeout": base},
+		Children: []Origin{
+			{File: env, Props: map[string]string{"enabled": base, "cert": env}, Children: []Origin{}},
+			{File: env, Args: env, Props: map[string]string{}, Children: []Origin{}},
+		},
+	}}, doc.Origins())
+}
+
+func TestLoadStrategies(t *testing.T) {
+	base := `
+servers { server "a"; }
+tls enabled=true { cert "a.pem"; }
+limits "x" rate=10 burst=5 { user "a"; }
+app { (t)logging level="info" format="json"; }`
+	override := `
+servers { server "b"; }
+tls cert="b.pem"
+limits rate=20 { user "b"; }
+app { logging level="debug"; }`
+
+	tests := []struct {
+		testname string
+		opts     []Option
+		expected string
+	}{
+		{
+			"merge",
+			nil,
+			`servers {
+    server "b"
+}
+tls enabled=true cert="b.pem" {
+    cert "a.pem"
+}
+limits "x" rate=20 burst=5 {
+    user "b"
+}
+app {
+    (t)logging level="debug" format="json"
+}
+`,
+		},
+		{
+			"replace",
+			[]Option{UseStrategy("tls", Replace), UseStrategy("app.logging", Replace)},
+			`servers {
+    server "b"
+}
+tls cert="b.pem"
+limits "x" rate=20 burst=5 {
+    user "b"
+}
+app {
+    logging level="debug"
+}
+`,
+		},
+		{
+			"append children",
+			[]Option{UseStrategy("servers", AppendChildren), UseStrategy("limits", AppendChildren)},
+			`servers {
+    server "a"
+    server "b"
+}
+tls enabled=true cert="b.pem" {
+    cert "a.pem"
+}
+limits "x" rate=20 burst=5 {
+    user "a"
+    user "b"
+}
+app {
+    (t)logging level="debug" format="json"
+}
+`,
+		},
+		{
+			"merge props",
+			[]Option{UseStrategy("tls", MergeProps), UseStrategy("limits", MergeProps)},
+			`servers {
+    server "b"
+}
+tls enabled=true cert="b.pem"
+limits rate=20 burst=5 {
+    user "b"
+}
+app {
+    (t)logging level="debug" format="json"
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Arrange
+			loader := NewLoader(test.opts...)
+			files := []string{writeFile(t, "base.kdl", base), writeFile(t, "override.kdl", override)}
+
+			// Act
+			doc, err := loader.Load(files...)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, test.expected, doc.KDL().String())
+		})
+	}
+}
+
+func TestLoadDuplicateNames(t *testing.T) {
+	// Arrange
+	base := writeFile(t, "base.kdl", "server 1 { a; }\nserver 2 { b; }")
+	override := writeFile(t, "override.kdl", "server 3\nserver { c; }\nserver 4")
+
+	// Act
+	doc, err := Load(base, override)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, `server 3 {
+    a
+}
+server 2 {
+    b
+    c
+}
+server 4
+`, doc.KDL().String())
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	// Arrange
+	base := writeFile(t, "base.kdl", "node 1")
+	missing := filepath.Join(t.TempDir(), "local.kdl")
+
+	// Act
+	_, err := Load(base, missing)
+	doc, ignoredErr := NewLoader(IgnoreMissing()).Load(base, missing)
+
+	// Assert
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, ignoredErr)
+	require.Equal(t, "node 1\n", doc.KDL().String())
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	// Arrange
+	base := writeFile(t, "base.kdl", "node 1")
+	invalid := writeFile(t, "invalid.kdl", "node\nnode {")
+
+	// Act
+	_, err := Load(base, invalid)
+
+	// Assert
+	var perr *gokdl.ParseError
+	require.True(t, errors.As(err, &perr))
+	require.Equal(t, invalid, perr.Pos.Filename)
+	require.Contains(t, err.Error(), invalid+":")
+}
+
+func TestMergeDoesNotModify(t *testing.T) {
+	// Arrange
+	loader := NewLoader(UseStrategy("node", AppendChildren))
+	first, err := gokdl.ParseString("node a=1 { child; }")
+	require.NoError(t, err)
+	second, err := gokdl.ParseString("node a=2 b=3 { other; }")
+	require.NoError(t, err)
+
+	// Act
+	merged := loader.Merge(loader.Merge(Doc{}, first, "first"), second, "second")
+	merged.Nodes()[0].Props[0].Value = int64(42)
+
+	// Assert
+	require.Equal(t, "node a=1 {\n    child\n}\n", first.String())
+	require.Equal(t, "node a=2 b=3 {\n    other\n}\n", second.String())
+}
+
+func TestDocLookup(t *testing.T) {
+	// Arrange
+	base := writeFile(t, "base.kdl", "server { tls enabled=false; }")
+	env := writeFile(t, "env.kdl", "server { tls enabled=true; }")
+	doc, err := Load(base, env)
+	require.NoError(t, err)
+
+	// Act
+	node, origin, ok := doc.Lookup("server.tls")
+	_, _, missing := doc.Lookup("server.cache")
+
+	// Assert
+	require.True(t, ok)
+	require.Equal(t, "tls", node.Name)
+	require.Equal(t, env, origin.Props["enabled"])
+	require.False(t, missing)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/lunjon/gokdl"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	// Added is a node that only exists in the new document.
+	Added ChangeKind = iota
+	// Removed is a node that only exists in the old document.
+	Removed
+	// Modified is a node whose type annotation, arguments
+	// or properties differ between the documents.
+	Modified
+)
+
+var changeKindNames = map[ChangeKind]string{
+	Added:    "Added",
+	Removed:  "Removed",
+	Modified: "Modified",
+}
+
+func (k ChangeKind) String() string {
+	return changeKindNames[k]
+}
+
+// Change is a difference in a node between two documents.
+type Change struct {
+	Kind ChangeKind
+	// Path of the node, e.g. "server.tls".
+	Path string
+	// Old and New are the node in the old and the new document.
+	// Old is the zero value if the node was added,
+	// and New if the node was removed.
+	Old gokdl.Node
+	New gokdl.Node
+}
+
+// Diff returns the changes from the old to the new document.
+// Nodes are matched like when merging, i.e. by name and path.
+// A node that is added or removed is a single change, while
+// the children of a node that exists in both documents are
+// compared recursively. The changes are in the order of the
+// nodes in the new document, with the nodes removed from a
+// scope after the other changes of the scope.
+func Diff(old, new Doc) []Change {
+	return diffScope(old.nodes, new.nodes, "", []Change{})
+}
+
+func diffScope(old, new []gokdl.Node, path string, changes []Change) []Change {
+	seen := map[string]int{}
+	matched := make([]bool, len(old))
+	for _, n := range new {
+		i := nthIndex(old, n.Name, seen[n.Name])
+		seen[n.Name]++
+		p := join(path, n.Name)
+		if i < 0 {
+			changes = append(changes, Change{Kind: Added, Path: p, New: n})
+			continue
+		}
+
+		matched[i] = true
+		o := old[i]
+		if !equalNodes(o, n) {
+			changes = append(changes, Change{Kind: Modified, Path: p, Old: o, New: n})
+		}
+		changes = diffScope(o.Children, n.Children, p, changes)
+	}
+
+	for i, o := range old {
+		if !matched[i] {
+			changes = append(changes, Change{Kind: Removed, Path: join(path, o.Name), Old: o})
+		}
+	}
+	return changes
+}
+
+// equalNodes reports whether the nodes are equal, not
+// considering their children. The order of the
+// properties does not matter.
+func equalNodes(a, b gokdl.Node) bool {
+	if a.TypeAnnotation != b.TypeAnnotation || len(a.Args) != len(b.Args) || len(a.Props) != len(b.Props) {
+		return false
+	}
+	for i := range a.Args {
+		if !reflect.DeepEqual(a.Args[i], b.Args[i]) {
+			return false
+		}
+	}
+	for _, p := range a.Props {
+		i := propIndex(b.Props, p.Name)
+		if i < 0 || !reflect.DeepEqual(p, b.Props[i]) {
+			return false
+		}
+	}
+	return true
+}
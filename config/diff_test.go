@@ -0,0 +1,71 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	type change struct {
+		kind ChangeKind
+		path string
+	}
+
+	tests := []struct {
+		testname string
+		old      string
+		new      string
+		expected []change
+	}{
+		{"equal", "a 1 x=2 { b; }", "a 1 x=2 { b; }", []change{}},
+		{"props in other order", "a x=1 y=2", "a y=2 x=1", []change{}},
+		{"added", "a", "a\nb", []change{{Added, "b"}}},
+		{"removed", "a\nb { c; }", "a", []change{{Removed, "b"}}},
+		{"arg", "a 1", "a 2", []change{{Modified, "a"}}},
+		{"arg type", "a 1", "a \"1\"", []change{{Modified, "a"}}},
+		{"prop", "a x=1", "a x=2", []change{{Modified, "a"}}},
+		{"prop added", "a x=1", "a x=1 y=2", []change{{Modified, "a"}}},
+		{"type annotation", "a 1", "(t)a 1", []change{{Modified, "a"}}},
+		{"child", "a { b 1; c; }", "a { b 2; c; d; }", []change{{Modified, "a.b"}, {Added, "a.d"}}},
+		{"child removed", "a { b; c; }", "a { c; }", []change{{Removed, "a.b"}}},
+		{"nested", "a 1 { b { c 1; }; }", "a 2 { b { c 2; }; }", []change{{Modified, "a"}, {Modified, "a.b.c"}}},
+		{"duplicate names", "s 1\ns 2", "s 1\ns 3\ns 4", []change{{Modified, "s"}, {Added, "s"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Arrange
+			old := setupDoc(t, test.old)
+			new := setupDoc(t, test.new)
+
+			// Act
+			changes := Diff(old, new)
+
+			// Assert
+			actual := []change{}
+			for _, c := range changes {
+				actual = append(actual, change{c.Kind, c.Path})
+			}
+			require.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDiffNodes(t *testing.T) {
+	// Arrange
+	old := setupDoc(t, "a 1\nb")
+	new := setupDoc(t, "a 2\nc")
+
+	// Act
+	changes := Diff(old, new)
+
+	// Assert
+	require.Len(t, changes, 3)
+	require.Equal(t, old.Nodes()[0], changes[0].Old)
+	require.Equal(t, new.Nodes()[0], changes[0].New)
+	require.Equal(t, new.Nodes()[1], changes[1].New)
+	require.Equal(t, "", changes[1].Old.Name)
+	require.Equal(t, old.Nodes()[1], changes[2].Old)
+	require.Equal(t, "", changes[2].New.Name)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"github.com/lunjon/gokdl"
+)
+
+// Doc is a configuration merged from one or more documents.
+type Doc struct {
+	nodes []gokdl.Node
+	// The origins of the nodes, in the same order.
+	origins []Origin
+}
+
+// Origin is the files that the parts of a merged node came from.
+type Origin struct {
+	// File is the last file that the node occurs in.
+	File string
+	// Args is the file that the arguments came from.
+	// It is empty if the node never had any arguments.
+	Args string
+	// Props maps the name of each property
+	// to the file that its value came from.
+	Props map[string]string
+	// Children are the origins of the
+	// children of the node, in the same order.
+	Children []Origin
+}
+
+// Nodes returns the merged nodes.
+func (d Doc) Nodes() []gokdl.Node {
+	return d.nodes
+}
+
+// Origins returns the origins of the nodes, in the same order.
+func (d Doc) Origins() []Origin {
+	return d.origins
+}
+
+// KDL returns the merged nodes as a KDL document,
+// e.g. to print the resulting configuration.
+func (d Doc) KDL() gokdl.Doc {
+	return gokdl.NewDoc(d.nodes)
+}
+
+// Lookup returns the node at path, e.g. "server.tls", and its origin.
+// If more than one node matches, the first is returned.
+// It returns false if there is no node at path.
+func (d Doc) Lookup(path string) (gokdl.Node, Origin, bool) {
+	names := split(path)
+	if len(names) == 0 {
+		return gokdl.Node{}, Origin{}, false
+	}
+
+	nodes, origins := d.nodes, d.origins
+	for depth, name := range names {
+		i := nthIndex(nodes, name, 0)
+		if i < 0 {
+			return gokdl.Node{}, Origin{}, false
+		}
+		if depth == len(names)-1 {
+			return nodes[i], origins[i], true
+		}
+		nodes, origins = nodes[i].Children, origins[i].Children
+	}
+	return gokdl.Node{}, Origin{}, false
+}
+
+func newOrigin(n gokdl.Node, file string) Origin {
+	o := Origin{
+		File:     file,
+		Props:    make(map[string]string, len(n.Props)),
+		Children: newOrigins(n.Children, file),
+	}
+	if len(n.Args) > 0 {
+		o.Args = file
+	}
+	for _, p := range n.Props {
+		o.Props[p.Name] = file
+	}
+	return o
+}
+
+func newOrigins(nodes []gokdl.Node, file string) []Origin {
+	origins := make([]Origin, len(nodes))
+	for i, n := range nodes {
+		origins[i] = newOrigin(n, file)
+	}
+	return origins
+}
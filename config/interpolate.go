@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lunjon/gokdl"
+)
+
+// ErrUndefined is wrapped by the error returned by Interpolate
+// when a reference is to a variable or value that is not
+// defined and has no default.
+var ErrUndefined = errors.New("undefined reference")
+
+// Resolver returns the value of a variable, e.g. an environment
+// variable. It returns false if the variable is not defined.
+type Resolver func(name string) (string, bool)
+
+// Env resolves the variables from the environment.
+func Env(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// Interpolate returns a copy of doc in which the references
+// in string arguments and property values are substituted.
+//
+// A reference is either ${NAME}, which is the value of the variable
+// NAME given by resolve, or ${path}, which is a value of the document
+// at a path with at least one dot. The last name of the path is
+// either a property of the node at the rest of the path, e.g. port in
+// server port=8080, or a child node whose first argument is the value,
+// e.g. port in server { port 8080; }. A default that is used if the
+// value is undefined or empty can be given as ${NAME:-default}.
+// To get a literal ${, write $${.
+//
+// If a string consists of a single reference, it is replaced by the
+// value itself, keeping its type, e.g. an integer. The values of
+// variables and defaults are then read as KDL values, so that
+// "${PORT:-8080}" is the integer 8080, unless the string has a type
+// annotation, e.g. (string)"${PORT}".
+//
+// An error is returned if a reference is undefined, wrapping
+// ErrUndefined, or if values refer to each other in a cycle.
+func Interpolate(doc Doc, resolve Resolver) (Doc, error) {
+	in := &interpolator{
+		doc:     doc,
+		resolve: resolve,
+		values:  map[string]any{},
+	}
+	nodes, err := in.nodes(doc.nodes, doc.origins, "")
+	if err != nil {
+		return Doc{}, err
+	}
+	return Doc{nodes: nodes, origins: doc.origins}, nil
+}
+
+type interpolator struct {
+	doc     Doc
+	resolve Resolver
+	// The values at the paths that have been referenced, and the paths
+	// that are being interpolated, to detect references in a cycle.
+	values   map[string]any
+	visiting []string
+}
+
+func (in *interpolator) nodes(nodes []gokdl.Node, origins []Origin, path string) ([]gokdl.Node, error) {
+	result := make([]gokdl.Node, len(nodes))
+	for i, n := range nodes {
+		o := origins[i]
+		p := join(path, n.Name)
+
+		n.Args = append([]gokdl.Arg{}, n.Args...)
+		for j, a := range n.Args {
+			v, err := in.value(a.Value, a.TypeAnnotation == "")
+			if err != nil {
+				return nil, interpolateError(o.Args, p, fmt.Sprintf("argument %d", j+1), err)
+			}
+			n.Args[j].Value = v
+		}
+
+		n.Props = append([]gokdl.Prop{}, n.Props...)
+		for j, prop := range n.Props {
+			v, err := in.value(prop.Value, prop.ValueTypeAnnot == "")
+			if err != nil {
+				return nil, interpolateError(o.Props[prop.Name], p, fmt.Sprintf("property %q", prop.Name), err)
+			}
+			n.Props[j].Value = v
+		}
+
+		children, err := in.nodes(n.Children, o.Children, p)
+		if err != nil {
+			return nil, err
+		}
+		n.Children = children
+		result[i] = n
+	}
+	return result, nil
+}
+
+// value returns v with its references substituted if it is a string.
+// If typed is true, a string that is a single reference is replaced
+// by the value itself instead of a string.
+func (in *interpolator) value(v any, typed bool) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated reference: %s", s[i:])
+			}
+			whole := typed && i == 0 && end == len(s)-1
+			v, err := in.reference(s[i+2:i+end], whole)
+			if err != nil {
+				return nil, err
+			}
+			if whole {
+				return v, nil
+			}
+			b.WriteString(format(v))
+			i += end + 1
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// reference returns the value of the reference ref, i.e. the text between ${ and }.
+// If typed is true, the values of variables and defaults are read as KDL values.
+func (in *interpolator) reference(ref string, typed bool) (any, error) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	if name == "" {
+		return nil, fmt.Errorf("empty reference: ${%s}", ref)
+	}
+
+	var v any
+	var ok bool
+	if strings.Contains(name, ".") {
+		var err error
+		if v, ok, err = in.lookup(name); err != nil {
+			return nil, err
+		}
+	} else if in.resolve != nil {
+		var s string
+		s, ok = in.resolve(name)
+		if ok && typed {
+			v = parseValue(s)
+		} else {
+			v = s
+		}
+	}
+
+	if hasDefault && (!ok || v == "") {
+		if typed {
+			return parseValue(def), nil
+		}
+		return def, nil
+	} else if !ok {
+		return nil, fmt.Errorf("%w: ${%s}", ErrUndefined, name)
+	}
+	return v, nil
+}
+
+// lookup returns the interpolated value at the path.
+func (in *interpolator) lookup(path string) (any, bool, error) {
+	if v, ok := in.values[path]; ok {
+		return v, true, nil
+	}
+	for i, p := range in.visiting {
+		if p == path {
+			cycle := append(append([]string{}, in.visiting[i:]...), path)
+			return nil, false, fmt.Errorf("reference cycle: ${%s}", strings.Join(cycle, "} -> ${"))
+		}
+	}
+
+	v, typed, ok := in.find(path)
+	if !ok {
+		return nil, false, nil
+	}
+
+	in.visiting = append(in.visiting, path)
+	v, err := in.value(v, typed)
+	in.visiting = in.visiting[:len(in.visiting)-1]
+	if err != nil {
+		return nil, false, err
+	}
+	in.values[path] = v
+	return v, true, nil
+}
+
+// find returns the value at the path as it is in the document,
+// and whether it has no type annotation.
+func (in *interpolator) find(path string) (any, bool, bool) {
+	i := strings.LastIndexByte(path, '.')
+	if node, _, ok := in.doc.Lookup(path[:i]); ok {
+		if j := propIndex(node.Props, path[i+1:]); j >= 0 {
+			prop := node.Props[j]
+			return prop.Value, prop.ValueTypeAnnot == "", true
+		}
+	}
+	if node, _, ok := in.doc.Lookup(path); ok && len(node.Args) > 0 {
+		arg := node.Args[0]
+		return arg.Value, arg.TypeAnnotation == "", true
+	}
+	return nil, false, false
+}
+
+// parseValue reads s as a KDL value, e.g. a number, if it is one.
+// Otherwise s is returned.
+func parseValue(s string) any {
+	if strings.TrimSpace(s) != s {
+		return s
+	}
+	doc, err := gokdl.ParseString("_ " + s)
+	if err != nil {
+		return s
+	}
+
+	nodes := doc.Nodes()
+	if len(nodes) != 1 || len(nodes[0].Args) != 1 || len(nodes[0].Props) > 0 || len(nodes[0].Children) > 0 {
+		return s
+	}
+	arg := nodes[0].Args[0]
+	if _, ok := arg.Value.(string); ok || arg.TypeAnnotation != "" {
+		return s
+	}
+	return arg.Value
+}
+
+// format returns the value as it is substituted into a string.
+func format(v any) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
+
+func interpolateError(file, path, item string, err error) error {
+	if file == "" {
+		return fmt.Errorf("%s: %s: %w", path, item, err)
+	}
+	return fmt.Errorf("%s: %s: %s: %w", file, path, item, err)
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/stretchr/testify/require"
+)
+
+var testVars = map[string]string{
+	"DB_HOST": "db.example.com",
+	"PORT":    "5432",
+	"DEBUG":   "true",
+	"EMPTY":   "",
+	"NAME":    "app-1",
+}
+
+func testResolver(name string) (string, bool) {
+	v, ok := testVars[name]
+	return v, ok
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		expected string
+	}{
+		{"variable", `db host="${DB_HOST}"`, `db host="db.example.com"`},
+		{"in text", `db url="postgres://${DB_HOST}:${PORT}/db"`, `db url="postgres://db.example.com:5432/db"`},
+		{"default", `db "${MISSING:-localhost}"`, `db "localhost"`},
+		{"default of empty", `db "${EMPTY:-localhost}"`, `db "localhost"`},
+		{"empty default", `db "a${MISSING:-}b"`, `db "ab"`},
+		{"defined with default", `db "${DB_HOST:-localhost}"`, `db "db.example.com"`},
+		{"typed", `db port="${PORT}" debug="${DEBUG}"`, `db port=5432 debug=true`},
+		{"typed default", `db port="${MISSING:-8080}"`, `db port=8080`},
+		{"typed not a value", `db name="${NAME}"`, `db name="app-1"`},
+		{"type annotation", `db port=(string)"${PORT}"`, `db port=(string)"5432"`},
+		{"escaped", `db "$${DB_HOST} costs $5"`, `db "${DB_HOST} costs $5"`},
+		{"not a reference", `db "$DB_HOST {x}"`, `db "$DB_HOST {x}"`},
+		{"property reference", "server port=8080\nclient port=\"${server.port}\"", "server port=8080\nclient port=8080"},
+		{"child reference", "server { port 8080; }\nclient url=\"http://localhost:${server.port}\"", "server {\n    port 8080\n}\nclient url=\"http://localhost:8080\""},
+		{"nested reference", "a x=\"${b.y}\"\nb y=\"${c.z}\"\nc z=\"${PORT}\"", "a x=5432\nb y=5432\nc z=5432"},
+		{"reference default", `client port="${server.port:-80}"`, `client port=80`},
+		{"null reference", "a x=null\nb \"x is ${a.x}\"", "a x=null\nb \"x is null\""},
+		{"in children", `server { tls cert="/etc/${NAME}.pem"; }`, "server {\n    tls cert=\"/etc/app-1.pem\"\n}"},
+		{"non-string", `db 1 true null`, `db 1 true null`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Arrange
+			doc := setupDoc(t, test.body)
+
+			// Act
+			interpolated, err := Interpolate(doc, testResolver)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, test.expected+"\n", interpolated.KDL().String())
+		})
+	}
+}
+
+func TestInterpolateInvalid(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		message  string
+	}{
+		{"undefined", `db host="${MISSING}"`, `file.kdl: db: property "host": undefined reference: ${MISSING}`},
+		{"undefined in child", `db { conn 1 "${MISSING}"; }`, `file.kdl: db.conn: argument 2: undefined reference: ${MISSING}`},
+		{"undefined path", `db host="${server.host}"`, `file.kdl: db: property "host": undefined reference: ${server.host}`},
+		{"unterminated", `db "${DB_HOST"`, `file.kdl: db: argument 1: unterminated reference: ${DB_HOST`},
+		{"empty", `db "${}"`, `file.kdl: db: argument 1: empty reference: ${}`},
+		{"cycle", "a x=\"${b.y}\"\nb y=\"${a.x}\"", `file.kdl: a: property "x": reference cycle: ${b.y} -> ${a.x} -> ${b.y}`},
+		{"self", `a x="${a.x}"`, `file.kdl: a: property "x": reference cycle: ${a.x} -> ${a.x}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Arrange
+			doc := setupDoc(t, test.body)
+
+			// Act
+			_, err := Interpolate(doc, testResolver)
+
+			// Assert
+			require.EqualError(t, err, test.message)
+		})
+	}
+}
+
+func TestInterpolateUndefined(t *testing.T) {
+	// Arrange
+	doc := setupDoc(t, `db "${DB_HOST}"`)
+
+	// Act
+	_, err := Interpolate(doc, nil)
+
+	// Assert
+	require.ErrorIs(t, err, ErrUndefined)
+}
+
+func TestLoadResolveVariables(t *testing.T) {
+	// Arrange
+	t.Setenv("GOKDL_TEST_HOST", "db.example.com")
+	base := writeFile(t, "base.kdl", `db host="${GOKDL_TEST_HOST:-localhost}" port=5432`)
+	env := writeFile(t, "env.kdl", `db url="postgres://${db.host}:${db.port}"`)
+
+	// Act
+	doc, err := NewLoader(ResolveVariables(Env)).Load(base, env)
+	fallback, fallbackErr := NewLoader(ResolveVariables(nil)).Load(base, env)
+	uninterpolated, noErr := Load(base, env)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "db host=\"db.example.com\" port=5432 url=\"postgres://db.example.com:5432\"\n", doc.KDL().String())
+	require.NoError(t, fallbackErr)
+	require.Equal(t, "db host=\"localhost\" port=5432 url=\"postgres://localhost:5432\"\n", fallback.KDL().String())
+	require.NoError(t, noErr)
+	require.Equal(t, "db host=\"${GOKDL_TEST_HOST:-localhost}\" port=5432 url=\"postgres://${db.host}:${db.port}\"\n", uninterpolated.KDL().String())
+}
+
+func setupDoc(t *testing.T, body string) Doc {
+	t.Helper()
+	doc, err := gokdl.ParseString(body)
+	require.NoError(t, err)
+	return NewLoader().Merge(Doc{}, doc, "file.kdl")
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Defaults of the options of Watch.
+const (
+	defaultPollInterval = time.Second
+	defaultDebounce     = 100 * time.Millisecond
+)
+
+// Notifier notifies of changes to files, e.g. using
+// the file system events of the operating system.
+type Notifier interface {
+	// Notify starts watching the file at path and returns a channel
+	// that receives a value when the file may have changed, e.g.
+	// when it was written, created or removed. Values may be dropped
+	// if the receiver is busy. Watching stops when ctx is done,
+	// after which the channel should be closed.
+	Notify(ctx context.Context, path string) (<-chan struct{}, error)
+}
+
+// UseNotifier makes Watch use the notifier to detect changes.
+// By default the file is polled every second, see Poller.
+func UseNotifier(n Notifier) Option {
+	return func(l *Loader) {
+		l.notifier = n
+	}
+}
+
+// Debounce sets how long Watch waits for the notifications
+// to stop before reloading the file, so that e.g. a file that
+// is written in many steps is only reloaded once. It is
+// 100ms by default.
+func Debounce(d time.Duration) Option {
+	return func(l *Loader) {
+		l.debounce = d
+	}
+}
+
+// Validate makes the loader validate the documents it loads
+// using fn. Load returns the error if the document is invalid,
+// and Watch only delivers valid documents.
+func Validate(fn func(Doc) error) Option {
+	return func(l *Loader) {
+		l.validate = fn
+	}
+}
+
+// OnError sets a function that is called with the errors of
+// Watch reloading a file, e.g. if it has a syntax error or
+// is invalid. By default such errors are ignored.
+func OnError(fn func(error)) Option {
+	return func(l *Loader) {
+		l.onError = fn
+	}
+}
+
+// Watch watches the file at path for changes using
+// the default loader, see Loader.Watch.
+func Watch(ctx context.Context, path string, fn func(old, new Doc, changes []Change)) error {
+	return NewLoader().Watch(ctx, path, fn)
+}
+
+// Watch loads the file at path, calls fn with the document, and
+// then calls fn each time the file changes, with the old and the new
+// document and the changes between them. Only documents that are
+// successfully loaded and validated are passed to fn; the errors of
+// other versions are passed to the OnError function, if any.
+// Versions without any changes are skipped.
+//
+// The first call has the zero Doc as old document, and every node
+// of the document as an Added change. fn is called by the goroutine
+// of Watch, which blocks until ctx is done and then returns ctx.Err(),
+// or until the channel of the notifier is closed and then returns nil.
+// An error is returned immediately if the file can not be loaded
+// at first.
+func (l *Loader) Watch(ctx context.Context, path string, fn func(old, new Doc, changes []Change)) error {
+	notifier := l.notifier
+	if notifier == nil {
+		notifier = Poller(defaultPollInterval)
+	}
+
+	// Start watching before the first load so that no change is missed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := notifier.Notify(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	doc, err := l.Load(path)
+	if err != nil {
+		return err
+	}
+	fn(Doc{}, doc, Diff(Doc{}, doc))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				return ctx.Err()
+			}
+		}
+
+		open, done := l.wait(ctx, events)
+		if done {
+			return ctx.Err()
+		}
+
+		next, err := l.Load(path)
+		if err != nil {
+			if l.onError != nil {
+				l.onError(err)
+			}
+		} else if changes := Diff(doc, next); len(changes) > 0 {
+			fn(doc, next, changes)
+			doc = next
+		}
+
+		if !open {
+			return ctx.Err()
+		}
+	}
+}
+
+// wait waits until no more values are received on events
+// for the debounce duration. It returns false if the channel
+// was closed, and true if ctx is done.
+func (l *Loader) wait(ctx context.Context, events <-chan struct{}) (open, done bool) {
+	d := l.debounce
+	if d == 0 {
+		d = defaultDebounce
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true, true
+		case <-timer.C:
+			return true, false
+		case _, ok := <-events:
+			if !ok {
+				return false, false
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(d)
+		}
+	}
+}
+
+// Poller returns a notifier that checks the size
+// and modification time of the file at the interval,
+// using only the standard library.
+func Poller(interval time.Duration) Notifier {
+	return poller{interval: interval}
+}
+
+type poller struct {
+	interval time.Duration
+}
+
+func (p poller) Notify(ctx context.Context, path string) (<-chan struct{}, error) {
+	last := statFile(path)
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if state := statFile(path); !state.equal(last) {
+				last = state
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// fileState is what the poller compares
+// to detect that a file has changed.
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+func (s fileState) equal(other fileState) bool {
+	return s.exists == other.exists && s.size == other.size && s.modTime.Equal(other.modTime)
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testNotifier struct {
+	events chan struct{}
+}
+
+func newTestNotifier() *testNotifier {
+	return &testNotifier{events: make(chan struct{})}
+}
+
+func (n *testNotifier) Notify(context.Context, string) (<-chan struct{}, error) {
+	return n.events, nil
+}
+
+type watchCall struct {
+	old, new Doc
+	changes  []Change
+}
+
+// startWatch runs Watch in a goroutine and returns
+// the calls of its function and its result.
+func startWatch(ctx context.Context, loader *Loader, path string) (<-chan watchCall, <-chan error) {
+	calls := make(chan watchCall, 10)
+	result := make(chan error, 1)
+	go func() {
+		result <- loader.Watch(ctx, path, func(old, new Doc, changes []Change) {
+			calls <- watchCall{old, new, changes}
+		})
+	}()
+	return calls, result
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+		var zero T
+		return zero
+	}
+}
+
+func TestWatch(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := writeFile(t, "config.kdl", "server port=8080")
+	notifier := newTestNotifier()
+	errs := make(chan error, 10)
+	loader := NewLoader(UseNotifier(notifier), Debounce(time.Millisecond), OnError(func(err error) {
+		errs <- err
+	}))
+
+	// Act
+	calls, result := startWatch(ctx, loader, path)
+	initial := receive(t, calls)
+
+	require.NoError(t, os.WriteFile(path, []byte("server port=9090\nlogging"), 0o644))
+	notifier.events <- struct{}{}
+	changed := receive(t, calls)
+
+	require.NoError(t, os.WriteFile(path, []byte("server {"), 0o644))
+	notifier.events <- struct{}{}
+	parseErr := receive(t, errs)
+
+	require.NoError(t, os.WriteFile(path, []byte("server port=9090\n// Only a comment\nlogging"), 0o644))
+	notifier.events <- struct{}{}
+	require.NoError(t, os.WriteFile(path, []byte("server port=9090"), 0o644))
+	notifier.events <- struct{}{}
+	removed := receive(t, calls)
+
+	cancel()
+	err := receive(t, result)
+
+	// Assert
+	require.Equal(t, Doc{}, initial.old)
+	require.Equal(t, "server port=8080\n", initial.new.KDL().String())
+	require.Equal(t, []Change{{Kind: Added, Path: "server", New: initial.new.Nodes()[0]}}, initial.changes)
+
+	require.Equal(t, initial.new, changed.old)
+	require.Equal(t, "server port=9090\nlogging\n", changed.new.KDL().String())
+	require.Len(t, changed.changes, 2)
+	require.Equal(t, Modified, changed.changes[0].Kind)
+	require.Equal(t, Added, changed.changes[1].Kind)
+
+	require.ErrorContains(t, parseErr, path)
+
+	require.Equal(t, changed.new, removed.old)
+	require.Equal(t, []Change{{Kind: Removed, Path: "logging", Old: changed.new.Nodes()[1]}}, removed.changes)
+	require.Empty(t, calls)
+
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWatchDebounce(t *testing.T) {
+	// Arrange
+	path := writeFile(t, "config.kdl", "a 1")
+	notifier := newTestNotifier()
+	var loads atomic.Int32
+	loader := NewLoader(UseNotifier(notifier), Debounce(100*time.Millisecond), Validate(func(Doc) error {
+		loads.Add(1)
+		return nil
+	}))
+
+	// Act
+	calls, result := startWatch(context.Background(), loader, path)
+	receive(t, calls)
+	for i := 2; i <= 5; i++ {
+		require.NoError(t, os.WriteFile(path, []byte{'a', ' ', byte('0' + i)}, 0o644))
+		notifier.events <- struct{}{}
+	}
+	call := receive(t, calls)
+	close(notifier.events)
+	err := receive(t, result)
+
+	// Assert
+	require.Equal(t, "a 5\n", call.new.KDL().String())
+	require.Equal(t, int32(2), loads.Load())
+	require.NoError(t, err)
+}
+
+func TestWatchInvalid(t *testing.T) {
+	// Arrange
+	path := writeFile(t, "config.kdl", "server port=8080")
+	notifier := newTestNotifier()
+	errs := make(chan error, 10)
+	errInvalid := errors.New("missing port")
+	loader := NewLoader(
+		UseNotifier(notifier),
+		Debounce(time.Millisecond),
+		OnError(func(err error) { errs <- err }),
+		Validate(func(doc Doc) error {
+			if node, _, ok := doc.Lookup("server"); !ok || len(node.Props) == 0 {
+				return errInvalid
+			}
+			return nil
+		}),
+	)
+
+	// Act
+	calls, _ := startWatch(context.Background(), loader, path)
+	receive(t, calls)
+	require.NoError(t, os.WriteFile(path, []byte("server"), 0o644))
+	notifier.events <- struct{}{}
+	invalidErr := receive(t, errs)
+	require.NoError(t, os.WriteFile(path, []byte("server port=9090"), 0o644))
+	notifier.events <- struct{}{}
+	call := receive(t, calls)
+	close(notifier.events)
+
+	// Assert
+	require.ErrorIs(t, invalidErr, errInvalid)
+	require.Equal(t, "server port=8080\n", call.old.KDL().String())
+	require.Equal(t, "server port=9090\n", call.new.KDL().String())
+}
+
+func TestWatchLoadError(t *testing.T) {
+	// Arrange
+	path := writeFile(t, "config.kdl", "server {")
+	called := false
+
+	// Act
+	err := NewLoader(UseNotifier(newTestNotifier())).Watch(context.Background(), path, func(_, _ Doc, _ []Change) {
+		called = true
+	})
+
+	// Assert
+	require.ErrorContains(t, err, path)
+	require.False(t, called)
+}
+
+func TestPoller(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := writeFile(t, "config.kdl", "a")
+	events, err := Poller(time.Millisecond).Notify(ctx, path)
+	require.NoError(t, err)
+
+	// Act
+	require.NoError(t, os.WriteFile(path, []byte("a 1"), 0o644))
+	_, changed := receiveOk(t, events)
+	require.NoError(t, os.Remove(path))
+	_, removed := receiveOk(t, events)
+	cancel()
+	_, open := receiveOk(t, events)
+
+	// Assert
+	require.True(t, changed)
+	require.True(t, removed)
+	require.False(t, open)
+}
+
+func receiveOk(t *testing.T, ch <-chan struct{}) (struct{}, bool) {
+	t.Helper()
+	select {
+	case v, ok := <-ch:
+		return v, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+		return struct{}{}, false
+	}
+}
@@ -0,0 +1,675 @@
+package gokdl
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Unmarshaler is implemented by types that
+// decode themselves from a node.
+type Unmarshaler interface {
+	UnmarshalKDL(node Node) error
+}
+
+// ValueUnmarshaler is implemented by types that decode themselves
+// from a value, i.e. an argument or the value of a property,
+// e.g. a duration like "5s". The type annotation of a property
+// value is passed as the type annotation of the argument.
+type ValueUnmarshaler interface {
+	UnmarshalKDLValue(value Arg) error
+}
+
+// DecodeError is the error returned when a
+// document can not be decoded into a value.
+type DecodeError struct {
+	// Path is the names of the nodes from the root, separated
+	// by dots, e.g. "server.tls", followed by the argument
+	// or property if the error is in one of them.
+	Path string
+	// Pos is the position of the node, argument or property.
+	Pos Position
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	msg := e.Err.Error()
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
+	}
+	if e.Pos.Line > 0 {
+		msg = e.Pos.String() + ": " + msg
+	}
+	return msg
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrors is the error returned when a document can not be
+// decoded into a value, with an error for every item that failed.
+// It unwraps to each of the errors, so errors.As finds the first.
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e DecodeErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Unmarshal parses the document in data and decodes
+// it into the value pointed to by v.
+//
+// The nodes of the document are decoded like the children of a
+// node. A node is decoded into a value depending on its type:
+//
+//   - An Unmarshaler decodes itself from the node.
+//   - A ValueUnmarshaler, an encoding.TextUnmarshaler and the basic
+//     types, e.g. string, int and bool, are decoded from the single
+//     argument of the node, as described below.
+//   - A struct is decoded field by field, as described by the tags.
+//   - A map with string keys is decoded from the children of the
+//     node, by name, and a slice from the arguments.
+//   - A Node or an empty interface is set to the node.
+//
+// A value, i.e. an argument or the value of a property, is decoded by
+// a ValueUnmarshaler if the type is one, or by an encoding.TextUnmarshaler
+// if the value is a string. Otherwise it is converted to the type if
+// possible, e.g. an integer to an int8 if it fits. Null decodes to the
+// zero value. Pointers are allocated as needed.
+//
+// The tag of a struct field is `kdl:"name,kind"`, where the kind is one of:
+//
+//   - arg: the next argument of the node.
+//   - args: the remaining arguments of the node, into a slice.
+//   - prop: the property with the name.
+//   - props: the properties that no other field is decoded from, into a map.
+//   - child: the child node with the name, or every child node with
+//     the name if the field is a slice. This is the default kind.
+//   - children: the child nodes that no other field is decoded from,
+//     into a slice or into a map by name.
+//   - name: the name of the node, into a string.
+//
+// The name defaults to the name of the field, and is then matched
+// ignoring case. Fields with the tag `kdl:"-"` are ignored. The fields
+// of embedded structs without a tag, or of pointers to them, which are
+// allocated, are decoded as if they were fields of the outer struct.
+//
+// The kind can be followed by options, e.g. `kdl:"port,prop,required"`:
+//
+//   - required: the item must be present. For the args and children
+//     kinds it means that there must be at least one.
+//   - default=value: the value if the item is not present, e.g.
+//     default=8080. It is a value like in a document, or a string.
+//   - min=n and max=n: the bounds of a number, or of the length of a
+//     string, a slice or a map. For the args kind they are the bounds
+//     of the number of arguments.
+//   - oneof=a b c: the values that the value may be, separated by spaces.
+//   - pattern=regexp: the pattern that the value must match. It is the
+//     rest of the tag, so it must be the last option.
+//
+// The values of a slice are validated one by one by oneof and pattern,
+// and an encoding.TextMarshaler by its text. Items that are not
+// present, and have no default, are not validated.
+//
+// Items that no field is decoded from are ignored, unless the option
+// DisallowUnknownFields is given. Decoding continues past the errors
+// of the items, e.g. a value that can not be converted, and every
+// error is returned as a DecodeErrors.
+func Unmarshal(data []byte, v any, opts ...Option) error {
+	p := newParserString(context.Background(), string(data))
+	p.opts = newOptions(opts)
+	return decode(p, v)
+}
+
+// A Decoder reads and decodes a document from a reader.
+type Decoder struct {
+	r    io.Reader
+	opts []Option
+}
+
+// NewDecoder returns a decoder that reads from r.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	return &Decoder{r: r, opts: opts}
+}
+
+// Decode reads the document from the reader and decodes it into
+// the value pointed to by v. See Unmarshal for how it is decoded.
+func (d *Decoder) Decode(v any) error {
+	p := newParser(d.r)
+	p.opts = newOptions(d.opts)
+	return decode(p, v)
+}
+
+func decode(p *parser, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("can not decode into a non-pointer or nil value: %T", v)
+	}
+
+	b, err := p.build(true)
+	if err != nil {
+		return err
+	}
+
+	root := &posNode{node: Node{Children: b.nodes, Props: []Prop{}, Args: []Arg{}}, children: b.roots}
+
+	ds := &decodeState{disallowUnknown: p.opts.disallowUnknownFields}
+	if err := ds.node(root, rv.Elem(), ""); err != nil {
+		return err
+	}
+	if len(ds.errs) > 0 {
+		return ds.errs
+	}
+	return nil
+}
+
+type decodeState struct {
+	// Report the items that no field is decoded from as errors.
+	disallowUnknown bool
+	errs            DecodeErrors
+}
+
+// fail records an error decoding the item at pos and path.
+func (ds *decodeState) fail(path string, pos Position, err error) {
+	ds.errs = append(ds.errs, &DecodeError{Path: path, Pos: pos, Err: err})
+}
+
+// node decodes the node n at path into v.
+func (ds *decodeState) node(n *posNode, v reflect.Value, path string) error {
+	v = allocate(v)
+	if u, ok := v.Addr().Interface().(Unmarshaler); ok {
+		if err := u.UnmarshalKDL(n.node); err != nil {
+			ds.fail(path, n.pos, err)
+		}
+		return nil
+	}
+
+	if v.Type() == nodeType {
+		v.Set(reflect.ValueOf(n.node))
+		return nil
+	}
+
+	if isValue(v) {
+		if len(n.node.Args) != 1 {
+			ds.fail(path, n.pos, fmt.Errorf("expected a single argument to decode into %s", v.Type()))
+		} else {
+			ds.value(n.node.Args[0], v, n.argPos[0], itemPath(path, "argument 1"))
+		}
+		// The arguments are reported above if there is not one
+		ds.unknown(n, len(n.node.Args), nil, nil, path)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return ds.structFields(n, v, path)
+	case reflect.Map:
+		ds.unknown(n, 0, nil, used(len(n.children)), path)
+		return ds.childMap(n.children, v, path)
+	case reflect.Slice:
+		ds.unknown(n, len(n.node.Args), nil, nil, path)
+		return ds.args(n, 0, v, constraints{}, path)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(n.node))
+			return nil
+		}
+	}
+	ds.fail(path, n.pos, fmt.Errorf("can not decode a node into %s", v.Type()))
+	return nil
+}
+
+// used returns n items that are all marked as used.
+func used(n int) []bool {
+	items := make([]bool, n)
+	for i := range items {
+		items[i] = true
+	}
+	return items
+}
+
+// unknown reports the items of n that no field is decoded from, if
+// unknown items are disallowed: the arguments from the index args,
+// and the properties and children that are not marked as used.
+func (ds *decodeState) unknown(n *posNode, args int, props, children []bool, path string) {
+	if !ds.disallowUnknown {
+		return
+	}
+
+	for i := args; i < len(n.node.Args); i++ {
+		ds.fail(itemPath(path, fmt.Sprintf("argument %d", i+1)), n.argPos[i], errors.New("unexpected argument"))
+	}
+	for i, p := range n.node.Props {
+		if props == nil || !props[i] {
+			ds.fail(itemPath(path, fmt.Sprintf("property %q", p.Name)), n.propPos[i], errors.New("unknown property"))
+		}
+	}
+	for i, c := range n.children {
+		if children == nil || !children[i] {
+			ds.fail(joinPath(path, c.node.Name), c.pos, errors.New("unknown node"))
+		}
+	}
+}
+
+func (ds *decodeState) structFields(n *posNode, v reflect.Value, path string) error {
+	fields := structFields(v.Type())
+	props := make([]bool, len(n.node.Props))
+	children := make([]bool, len(n.children))
+	arg := 0
+
+	// The fields that are decoded from the items
+	// that no other field is decoded from are last
+	var rest []field
+	for _, f := range fields {
+		if f.err != nil {
+			return f.err
+		}
+
+		fv, _ := fieldByIndex(v, f.index, true)
+		switch f.kind {
+		case kindArg:
+			argPath := itemPath(path, fmt.Sprintf("argument %d", arg+1))
+			if arg < len(n.node.Args) {
+				if ds.value(n.node.Args[arg], fv, n.argPos[arg], argPath) {
+					ds.validate(f.constraints, fv, n.argPos[arg], argPath)
+				}
+			} else if f.required {
+				ds.fail(path, n.pos, fmt.Errorf("missing required argument %d", arg+1))
+			} else if err := ds.defaultValue(f, fv, n.pos, argPath); err != nil {
+				return err
+			}
+			arg++
+		case kindArgs:
+			if f.required && arg >= len(n.node.Args) {
+				ds.fail(path, n.pos, fmt.Errorf("missing required argument %d", arg+1))
+			}
+			if err := ds.args(n, arg, fv, f.constraints.values(), path); err != nil {
+				return err
+			}
+			ds.bounds(f.constraints, "number of arguments", float64(allocate(fv).Len()), n.pos, path)
+			arg = max(arg, len(n.node.Args))
+		case kindProp:
+			found := false
+			for i, p := range n.node.Props {
+				if !f.matches(p.Name) {
+					continue
+				}
+				found = true
+				props[i] = true
+				value := Arg{Value: p.Value, TypeAnnotation: p.ValueTypeAnnot}
+				propPath := itemPath(path, fmt.Sprintf("property %q", p.Name))
+				if ds.value(value, fv, n.propPos[i], propPath) {
+					ds.validate(f.constraints, fv, n.propPos[i], propPath)
+				}
+			}
+			if found {
+				break
+			}
+			if f.required {
+				ds.fail(path, n.pos, fmt.Errorf("missing required property %q", f.name))
+			} else if err := ds.defaultValue(f, fv, n.pos, itemPath(path, fmt.Sprintf("property %q", f.name))); err != nil {
+				return err
+			}
+		case kindChild:
+			found, err := ds.child(n, f, fv, children, path)
+			if err != nil {
+				return err
+			}
+			if found != nil {
+				ds.validate(f.constraints, fv, found.pos, joinPath(path, found.node.Name))
+				break
+			}
+			if f.required {
+				ds.fail(path, n.pos, fmt.Errorf("missing required node %q", f.name))
+			} else if err := ds.defaultValue(f, fv, n.pos, joinPath(path, f.name)); err != nil {
+				return err
+			}
+		case kindName:
+			if fv.Kind() != reflect.String {
+				return fmt.Errorf("can not decode the name of a node into field %s of type %s", f.goName, fv.Type())
+			}
+			fv.SetString(n.node.Name)
+		case kindProps, kindChildren:
+			rest = append(rest, f)
+		default:
+			return fmt.Errorf("invalid kind %q in the tag of field %s", f.kind, f.goName)
+		}
+	}
+
+	for _, f := range rest {
+		fv, _ := fieldByIndex(v, f.index, true)
+		if f.kind == kindProps {
+			if err := ds.propMap(n, props, fv, path); err != nil {
+				return err
+			}
+			props = used(len(props))
+			continue
+		}
+
+		var unused []*posNode
+		for i, c := range n.children {
+			if !children[i] {
+				unused = append(unused, c)
+				children[i] = true
+			}
+		}
+		if f.required && len(unused) == 0 {
+			ds.fail(path, n.pos, errors.New("missing required child nodes"))
+		}
+		if err := ds.childList(unused, fv, path); err != nil {
+			return err
+		}
+	}
+
+	ds.unknown(n, arg, props, children, path)
+	return nil
+}
+
+// child decodes the children of n matching the field f into v, and
+// marks them as used. If v is a slice, every matching child is decoded
+// into it, otherwise the last one. It returns the first child that is
+// decoded, or nil if none matches.
+func (ds *decodeState) child(n *posNode, f field, v reflect.Value, used []bool, path string) (*posNode, error) {
+	var matched []*posNode
+	for i, c := range n.children {
+		if f.matches(c.node.Name) {
+			used[i] = true
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	if v.Kind() == reflect.Slice && !isValue(v) {
+		return matched[0], ds.nodeSlice(matched, v, path)
+	}
+	c := matched[len(matched)-1]
+	return c, ds.node(c, v, joinPath(path, c.node.Name))
+}
+
+// childList decodes the nodes into v, which is
+// a slice of the nodes, or a map of them by name.
+func (ds *decodeState) childList(nodes []*posNode, v reflect.Value, path string) error {
+	switch allocate(v).Kind() {
+	case reflect.Slice:
+		return ds.nodeSlice(nodes, allocate(v), path)
+	case reflect.Map:
+		return ds.childMap(nodes, allocate(v), path)
+	}
+	return fmt.Errorf("can not decode children into %s", v.Type())
+}
+
+func (ds *decodeState) nodeSlice(nodes []*posNode, v reflect.Value, path string) error {
+	s := reflect.MakeSlice(v.Type(), len(nodes), len(nodes))
+	for i, c := range nodes {
+		if err := ds.node(c, s.Index(i), joinPath(path, c.node.Name)); err != nil {
+			return err
+		}
+	}
+	v.Set(s)
+	return nil
+}
+
+// childMap decodes the nodes into the map v by name.
+func (ds *decodeState) childMap(nodes []*posNode, v reflect.Value, path string) error {
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		return fmt.Errorf("can not decode nodes into %s: the keys are not strings", t)
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+
+	for _, c := range nodes {
+		elem := reflect.New(t.Elem()).Elem()
+		if err := ds.node(c, elem, joinPath(path, c.node.Name)); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(c.node.Name).Convert(t.Key()), elem)
+	}
+	return nil
+}
+
+// propMap decodes the properties of n that are not used into the map v.
+func (ds *decodeState) propMap(n *posNode, used []bool, v reflect.Value, path string) error {
+	v = allocate(v)
+	t := v.Type()
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return fmt.Errorf("can not decode properties into %s", t)
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+
+	for i, p := range n.node.Props {
+		if used[i] {
+			continue
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		value := Arg{Value: p.Value, TypeAnnotation: p.ValueTypeAnnot}
+		ds.value(value, elem, n.propPos[i], itemPath(path, fmt.Sprintf("property %q", p.Name)))
+		v.SetMapIndex(reflect.ValueOf(p.Name).Convert(t.Key()), elem)
+	}
+	return nil
+}
+
+// args decodes the arguments of n from the index start
+// into the slice v, and validates them by c.
+func (ds *decodeState) args(n *posNode, start int, v reflect.Value, c constraints, path string) error {
+	v = allocate(v)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("can not decode arguments into %s", v.Type())
+	}
+
+	args := n.node.Args[min(start, len(n.node.Args)):]
+	s := reflect.MakeSlice(v.Type(), len(args), len(args))
+	for i, a := range args {
+		pos, path := n.argPos[start+i], itemPath(path, fmt.Sprintf("argument %d", start+i+1))
+		if ds.value(a, s.Index(i), pos, path) {
+			ds.validateValue(c, s.Index(i), pos, path)
+		}
+	}
+	v.Set(s)
+	return nil
+}
+
+// value decodes the value a, at pos, into v. It returns false if it fails.
+func (ds *decodeState) value(a Arg, v reflect.Value, pos Position, path string) bool {
+	if err := setValue(a, v); err != nil {
+		ds.fail(path, pos, err)
+		return false
+	}
+	return true
+}
+
+// defaultValue sets v, the value of the field f, to the default of the
+// field if it has one, as if it was decoded from the item at pos and path.
+func (ds *decodeState) defaultValue(f field, v reflect.Value, pos Position, path string) error {
+	if f.def == nil {
+		return nil
+	}
+	if err := setValue(*f.def, v); err != nil {
+		return fmt.Errorf("invalid default %q in the tag of field %s: %w", f.def.Value, f.goName, err)
+	}
+	ds.validate(f.constraints, v, pos, path)
+	return nil
+}
+
+func setValue(a Arg, v reflect.Value) error {
+	if a.Value == nil && v.Kind() == reflect.Pointer {
+		v.SetZero()
+		return nil
+	}
+
+	v = allocate(v)
+	if u, ok := v.Addr().Interface().(ValueUnmarshaler); ok {
+		return u.UnmarshalKDLValue(a)
+	}
+	if a.Value == nil {
+		v.SetZero()
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if s, ok := a.Value.(string); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if s, ok := a.Value.(string); ok {
+			v.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := a.Value.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch n := a.Value.(type) {
+		case int64:
+			if v.OverflowInt(n) {
+				return fmt.Errorf("value %d overflows %s", n, v.Type())
+			}
+			v.SetInt(n)
+			return nil
+		case uint64:
+			if n > math.MaxInt64 || v.OverflowInt(int64(n)) {
+				return fmt.Errorf("value %d overflows %s", n, v.Type())
+			}
+			v.SetInt(int64(n))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch n := a.Value.(type) {
+		case int64:
+			if n < 0 || v.OverflowUint(uint64(n)) {
+				return fmt.Errorf("value %d overflows %s", n, v.Type())
+			}
+			v.SetUint(uint64(n))
+			return nil
+		case uint64:
+			if v.OverflowUint(n) {
+				return fmt.Errorf("value %d overflows %s", n, v.Type())
+			}
+			v.SetUint(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := a.Value.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		case uint64:
+			f = float64(n)
+		default:
+			return errors.New(cannotDecode(a.Value, v.Type()))
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("value %g overflows %s", f, v.Type())
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(a.Value))
+			return nil
+		}
+	}
+	return errors.New(cannotDecode(a.Value, v.Type()))
+}
+
+func cannotDecode(value any, t reflect.Type) string {
+	return fmt.Sprintf("can not decode %s into %s", valueTypeName(value), t)
+}
+
+// valueTypeName returns the name of the KDL type of the value.
+func valueTypeName(v any) ValueType {
+	switch v.(type) {
+	case nil:
+		return TypeNull
+	case string:
+		return TypeString
+	case bool:
+		return TypeBool
+	case int64, uint64:
+		return TypeInt
+	case float64:
+		return TypeFloat
+	}
+	return ValueType(fmt.Sprintf("%T", v))
+}
+
+// allocate follows the pointers from v, allocating
+// the nil ones, and returns the value pointed to.
+func allocate(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isValue reports whether v, which is not
+// a pointer, is decoded from a single value.
+func isValue(v reflect.Value) bool {
+	t := reflect.PointerTo(v.Type())
+	return isBasic(v) || t.Implements(valueUnmarshalerType) || t.Implements(textUnmarshalerType)
+}
+
+// isBasic reports whether v is of a basic type,
+// i.e. one that a value can be converted to.
+func isBasic(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+var (
+	nodeType             = reflect.TypeOf(Node{})
+	valueUnmarshalerType = reflect.TypeOf((*ValueUnmarshaler)(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// itemPath returns the path of an item, e.g. a property, of the node at path.
+func itemPath(path, item string) string {
+	if path == "" {
+		return item
+	}
+	return path + ": " + item
+}
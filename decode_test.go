@@ -0,0 +1,528 @@
+package gokdl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Duration decodes itself from a value like "5s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalKDLValue(value Arg) error {
+	s, ok := value.Value.(string)
+	if !ok {
+		return fmt.Errorf("expected a duration string")
+	}
+	parsed, err := time.ParseDuration(s)
+	*d = Duration(parsed)
+	return err
+}
+
+func (d Duration) MarshalKDLValue() (Arg, error) {
+	return Arg{Value: time.Duration(d).String()}, nil
+}
+
+// ByteSize decodes itself from a number of bytes,
+// or from a string with a unit like "2mb".
+type ByteSize int64
+
+func (b *ByteSize) UnmarshalKDLValue(value Arg) error {
+	switch v := value.Value.(type) {
+	case int64:
+		*b = ByteSize(v)
+	case string:
+		units := map[string]int64{"kb": 1 << 10, "mb": 1 << 20}
+		if len(v) < 3 || units[v[len(v)-2:]] == 0 {
+			return fmt.Errorf("invalid size: %s", v)
+		}
+		n, err := strconv.ParseInt(v[:len(v)-2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid size: %s", v)
+		}
+		*b = ByteSize(n * units[v[len(v)-2:]])
+	default:
+		return fmt.Errorf("expected a size")
+	}
+	return nil
+}
+
+// LogLevel implements the text interfaces.
+type LogLevel int
+
+var logLevels = []string{"debug", "info", "warn"}
+
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	for i, name := range logLevels {
+		if name == string(text) {
+			*l = LogLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level: %s", text)
+}
+
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(logLevels[l]), nil
+}
+
+// Endpoint decodes itself from a node like: endpoint "host" 8080
+type Endpoint struct {
+	Addr string
+}
+
+func (e *Endpoint) UnmarshalKDL(node Node) error {
+	if len(node.Args) != 2 {
+		return errors.New("expected a host and a port")
+	}
+	e.Addr = fmt.Sprintf("%v:%v", node.Args[0].Value, node.Args[1].Value)
+	return nil
+}
+
+func (e Endpoint) MarshalKDL() (Node, error) {
+	host, port, _ := strings.Cut(e.Addr, ":")
+	n, err := strconv.ParseInt(port, 10, 64)
+	return Node{Args: []Arg{{Value: host}, {Value: n}}}, err
+}
+
+type testServer struct {
+	Name     string            `kdl:",arg"`
+	Port     uint16            `kdl:"port,prop"`
+	Timeout  Duration          `kdl:"timeout,prop"`
+	MaxBody  ByteSize          `kdl:"max-body,prop"`
+	Level    *LogLevel         `kdl:"level,prop"`
+	Labels   map[string]string `kdl:",props"`
+	Upstream []Endpoint        `kdl:"upstream"`
+	TLS      *testTLS          `kdl:"tls"`
+}
+
+type testTLS struct {
+	Cert string `kdl:"cert,prop"`
+	Key  string `kdl:"key,prop"`
+}
+
+type testConfig struct {
+	Debug   bool
+	Workers int
+	Hosts   []string          `kdl:"host"`
+	Servers []testServer      `kdl:"server"`
+	Env     map[string]string `kdl:"env"`
+	Ignored string            `kdl:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	// Arrange
+	src := `
+debug true
+workers 4
+host "a.example.com"
+host "b.example.com"
+server "api" port=8080 timeout="1m30s" max-body="2mb" level="warn" zone="eu" {
+    upstream "10.0.0.1" 9000
+    upstream "10.0.0.2" 9000
+    tls cert="api.pem" key="api.key"
+}
+server "admin" port=9090
+env {
+    HOME "/root"
+    PATH "/bin"
+}
+ignored "x"
+`
+	warn := LogLevel(2)
+
+	// Act
+	var config testConfig
+	err := Unmarshal([]byte(src), &config)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, testConfig{
+		Debug:   true,
+		Workers: 4,
+		Hosts:   []string{"a.example.com", "b.example.com"},
+		Servers: []testServer{
+			{
+				Name:     "api",
+				Port:     8080,
+				Timeout:  Duration(90 * time.Second),
+				MaxBody:  2 << 20,
+				Level:    &warn,
+				Labels:   map[string]string{"zone": "eu"},
+				Upstream: []Endpoint{{"10.0.0.1:9000"}, {"10.0.0.2:9000"}},
+				TLS:      &testTLS{Cert: "api.pem", Key: "api.key"},
+			},
+			{
+				Name:   "admin",
+				Port:   9090,
+				Labels: map[string]string{},
+			},
+		},
+		Env: map[string]string{"HOME": "/root", "PATH": "/bin"},
+	}, config)
+}
+
+func TestUnmarshalKinds(t *testing.T) {
+	type item struct {
+		Name  string `kdl:",name"`
+		Value int    `kdl:",arg"`
+	}
+	type embedded struct {
+		Extra string `kdl:"extra,prop"`
+	}
+	type node struct {
+		embedded
+		First    string         `kdl:",arg"`
+		Rest     []any          `kdl:",args"`
+		Items    []item         `kdl:",children"`
+		Raw      Node           `kdl:"raw"`
+		Any      any            `kdl:"any"`
+		Optional *float32       `kdl:"optional,prop"`
+		Null     *int           `kdl:"none,prop"`
+		ByName   map[string]int `kdl:"by-name"`
+	}
+	src := `n "a" 1 2.5 null extra="x" optional=1 none=null {
+    raw 1 x=2
+    any
+    by-name { a 1; b 2; }
+    c 3
+    d 4
+}`
+
+	// Act
+	var doc struct {
+		N node `kdl:"n"`
+	}
+	err := Unmarshal([]byte(src), &doc)
+
+	// Assert
+	require.NoError(t, err)
+	optional := float32(1)
+	require.Equal(t, node{
+		embedded: embedded{Extra: "x"},
+		First:    "a",
+		Rest:     []any{int64(1), 2.5, nil},
+		Items:    []item{{"c", 3}, {"d", 4}},
+		Raw: Node{
+			Name:     "raw",
+			Args:     []Arg{{Value: int64(1)}},
+			Props:    []Prop{{Name: "x", Value: int64(2)}},
+			Children: []Node{},
+		},
+		Any:      Node{Name: "any", Args: []Arg{}, Props: []Prop{}, Children: []Node{}},
+		Optional: &optional,
+		ByName:   map[string]int{"a": 1, "b": 2},
+	}, doc.N)
+}
+
+func TestUnmarshalEmbeddedPointer(t *testing.T) {
+	type Embedded struct {
+		X int `kdl:"x,prop"`
+	}
+	type node struct {
+		*Embedded
+		Y int `kdl:"y,prop"`
+	}
+
+	// Act
+	var doc struct {
+		N node `kdl:"n"`
+	}
+	err := Unmarshal([]byte("n x=1 y=2"), &doc)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, node{Embedded: &Embedded{X: 1}, Y: 2}, doc.N)
+}
+
+func TestUnmarshalMap(t *testing.T) {
+	// Act
+	var doc map[string][]int
+	err := Unmarshal([]byte("a 1 2\nb"), &doc)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, map[string][]int{"a": {1, 2}, "b": {}}, doc)
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	type tls struct {
+		Cert string `kdl:"cert,prop"`
+	}
+	type server struct {
+		Port    int8       `kdl:"port,prop"`
+		Timeout Duration   `kdl:"timeout,prop"`
+		Level   LogLevel   `kdl:"level,prop"`
+		Size    uint       `kdl:",arg"`
+		TLS     tls        `kdl:"tls"`
+		Ends    []Endpoint `kdl:"end"`
+	}
+	type config struct {
+		Server server
+		Count  int
+	}
+
+	tests := []struct {
+		testname string
+		body     string
+		message  string
+	}{
+		{"overflow", "server port=128", `1:8: server: property "port": value 128 overflows int8`},
+		{"negative unsigned", "server -1", `1:8: server: argument 1: value -1 overflows uint`},
+		{"wrong type", "server {\n    tls cert=1\n}", `2:9: server.tls: property "cert": can not decode int into string`},
+		{"value unmarshaler", `server timeout="5 minutes"`, `1:8: server: property "timeout": time: unknown unit " minutes" in duration "5 minutes"`},
+		{"text unmarshaler", `server level="trace"`, `1:8: server: property "level": unknown log level: trace`},
+		{"text unmarshaler wrong type", `server level=true`, `1:8: server: property "level": can not decode boolean into gokdl.LogLevel`},
+		{"unmarshaler", `server { end "a"; }`, `1:10: server.end: expected a host and a port`},
+		{"no argument", "count", `1:1: count: expected a single argument to decode into int`},
+		{"too many arguments", "count 1 2", `1:1: count: expected a single argument to decode into int`},
+		{"float into int", "count 1.5", `1:7: count: argument 1: can not decode float into int`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			var c config
+			err := Unmarshal([]byte(test.body), &c)
+
+			// Assert
+			var derr *DecodeError
+			require.ErrorAs(t, err, &derr)
+			require.EqualError(t, err, test.message)
+		})
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	type tls struct {
+		Cert string `kdl:"cert,prop,required"`
+	}
+	type server struct {
+		Name  string            `kdl:",arg,required"`
+		Port  int               `kdl:"port,prop,required"`
+		TLS   *tls              `kdl:"tls,required"`
+		Hosts []string          `kdl:",args"`
+		Env   map[string]string `kdl:"env"`
+	}
+	type config struct {
+		Server  server
+		Backup  server
+		Workers int
+	}
+	src := `server "api" "a" host="x" port="80" {
+    tls key="k"
+    cache 1
+    env { HOME "/root"; }
+}
+workers 2 limit=4
+backup
+logging`
+
+	// Act
+	var c config
+	err := Unmarshal([]byte(src), &c, DisallowUnknownFields())
+
+	// Assert
+	var derrs DecodeErrors
+	require.ErrorAs(t, err, &derrs)
+	require.EqualError(t, err, `1:27: server: property "port": can not decode string into int
+2:5: server.tls: missing required property "cert"
+2:9: server.tls: property "key": unknown property
+1:18: server: property "host": unknown property
+3:5: server.cache: unknown node
+7:1: backup: missing required argument 1
+7:1: backup: missing required property "port"
+7:1: backup: missing required node "tls"
+6:11: workers: property "limit": unknown property
+8:1: logging: unknown node`)
+	require.Equal(t, []string{"a"}, c.Server.Hosts)
+	require.Equal(t, map[string]string{"HOME": "/root"}, c.Server.Env)
+}
+
+func TestUnmarshalStrictArgs(t *testing.T) {
+	type node struct {
+		Name string `kdl:",arg"`
+	}
+
+	// Act
+	var doc struct {
+		Node   node
+		Limits map[string]int
+	}
+	src := "node \"a\" 1 2\nlimits 1 max=2 { a 1; }"
+	err := Unmarshal([]byte(src), &doc, DisallowUnknownFields())
+	lenientErr := Unmarshal([]byte(src), &doc)
+
+	// Assert
+	require.EqualError(t, err, `1:10: node: argument 2: unexpected argument
+1:12: node: argument 3: unexpected argument
+2:8: limits: argument 1: unexpected argument
+2:10: limits: property "max": unknown property`)
+	require.NoError(t, lenientErr)
+	require.Equal(t, map[string]int{"a": 1}, doc.Limits)
+}
+
+func TestUnmarshalOptions(t *testing.T) {
+	type server struct {
+		Host string `kdl:",arg"`
+		Port int    `kdl:"port,prop"`
+	}
+	type config struct {
+		Servers []server `kdl:"server"`
+	}
+	fsys := fstest.MapFS{
+		"servers.kdl": {Data: []byte("server \"b\" port=2\nserver \"c\" port=3 host=1")},
+	}
+	src := "server \"a\" port=1\ninclude \"servers.kdl\""
+
+	// Act
+	var c config
+	err := Unmarshal([]byte(src), &c, IncludeFiles(fsys), DisallowUnknownFields())
+
+	// Assert
+	require.EqualError(t, err, `servers.kdl:2:19: server: property "host": unknown property`)
+	require.Equal(t, []server{{Host: "a", Port: 1}, {Host: "b", Port: 2}, {Host: "c", Port: 3}}, c.Servers)
+}
+
+func TestUnmarshalKeepDuplicateProps(t *testing.T) {
+	// Act
+	var doc struct {
+		Node Node `kdl:"node"`
+	}
+	err := Unmarshal([]byte("node a=1 b=2 a=3"), &doc, KeepDuplicateProps())
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, []Prop{
+		{Name: "a", Value: int64(1)},
+		{Name: "b", Value: int64(2)},
+		{Name: "a", Value: int64(3)},
+	}, doc.Node.Props)
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	type server struct {
+		Host    string   `kdl:",arg,default=localhost"`
+		Port    int      `kdl:"port,prop,default=8080"`
+		TLS     bool     `kdl:"tls,prop,default=true"`
+		Timeout Duration `kdl:"timeout,prop,default=1m30s"`
+		Level   LogLevel `kdl:"level,default=info"`
+		Ratio   *float64 `kdl:"ratio,prop,default=0.5"`
+	}
+
+	// Act
+	var doc struct {
+		Servers []server `kdl:"server"`
+	}
+	err := Unmarshal([]byte("server\nserver \"example.com\" port=443 tls=false { level \"warn\"; }"), &doc)
+
+	// Assert
+	require.NoError(t, err)
+	ratio := 0.5
+	require.Equal(t, []server{
+		{Host: "localhost", Port: 8080, TLS: true, Timeout: Duration(90 * time.Second), Level: 1, Ratio: &ratio},
+		{Host: "example.com", Port: 443, TLS: false, Timeout: Duration(90 * time.Second), Level: 2, Ratio: &ratio},
+	}, doc.Servers)
+}
+
+func TestUnmarshalValidation(t *testing.T) {
+	type server struct {
+		Name  string   `kdl:",arg,pattern=^[a-z]{2,8}$"`
+		Hosts []string `kdl:",args,max=2,pattern=^[a-z.]+$"`
+		Port  int      `kdl:"port,prop,min=1,max=65535"`
+		Level LogLevel `kdl:"level,prop,default=info,oneof=info warn"`
+		Tags  []string `kdl:"tag,max=1"`
+		Mode  string   `kdl:"mode,oneof=fast safe"`
+	}
+	src := `server "Api" "a.com" "B.com" "c.com" port=70000 level="debug" {
+    mode "slow"
+    tag "a"
+    tag "b"
+}
+server "admin" port=0`
+
+	// Act
+	var doc struct {
+		Servers []server `kdl:"server"`
+	}
+	err := Unmarshal([]byte(src), &doc)
+
+	// Assert
+	var derrs DecodeErrors
+	require.ErrorAs(t, err, &derrs)
+	require.EqualError(t, err, `1:8: server: argument 1: value "Api" does not match the pattern ^[a-z]{2,8}$
+1:22: server: argument 3: value "B.com" does not match the pattern ^[a-z.]+$
+1:1: server: number of arguments 3 is greater than the maximum 2
+1:38: server: property "port": value 70000 is greater than the maximum 65535
+1:49: server: property "level": value "debug" is not one of info, warn
+3:5: server.tag: length 2 is greater than the maximum 1
+2:5: server.mode: value "slow" is not one of fast, safe
+6:16: server: property "port": value 0 is less than the minimum 1`)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	// Act
+	var n int
+	nonPointerErr := Unmarshal([]byte("a"), n)
+	parseErr := Unmarshal([]byte("a {"), &n)
+	var tagged struct {
+		A int `kdl:"a,unknown"`
+	}
+	tagErr := Unmarshal([]byte("a 1"), &tagged)
+	var option struct {
+		A int `kdl:"a,prop,max=ten"`
+	}
+	optionErr := Unmarshal([]byte("a 1"), &option)
+	var pattern struct {
+		A string `kdl:"a,pattern=^[a-z"`
+	}
+	patternErr := Unmarshal([]byte("a \"b\""), &pattern)
+	var def struct {
+		A int `kdl:"a,prop,default=none"`
+	}
+	defErr := Unmarshal([]byte("a"), &def)
+
+	// Assert
+	require.EqualError(t, nonPointerErr, "can not decode into a non-pointer or nil value: int")
+	var perr *ParseError
+	require.ErrorAs(t, parseErr, &perr)
+	require.EqualError(t, tagErr, `invalid kind "unknown" in the tag of field A`)
+	require.EqualError(t, optionErr, `invalid option "max=ten" in the tag of field A: strconv.ParseFloat: parsing "ten": invalid syntax`)
+	require.EqualError(t, patternErr, "invalid option \"pattern=^[a-z\" in the tag of field A: error parsing regexp: missing closing ]: `[a-z`")
+	require.EqualError(t, defErr, `invalid default "none" in the tag of field A: can not decode string into int`)
+}
+
+func TestDecoder(t *testing.T) {
+	// Arrange
+	dec := NewDecoder(strings.NewReader("host \"a\"\nhost \"b\"\nworkers 2"))
+
+	// Act
+	var config testConfig
+	err := dec.Decode(&config)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, config.Hosts)
+	require.Equal(t, 2, config.Workers)
+}
+
+func TestDecoderStrict(t *testing.T) {
+	// Arrange
+	dec := NewDecoder(strings.NewReader("workers 2\nthreads 4"), DisallowUnknownFields())
+
+	// Act
+	var config testConfig
+	err := dec.Decode(&config)
+
+	// Assert
+	var derr *DecodeError
+	require.ErrorAs(t, err, &derr)
+	require.Equal(t, "threads", derr.Path)
+	require.EqualError(t, err, "2:1: threads: unknown node")
+}
@@ -2,8 +2,23 @@ package gokdl
 
 type Doc struct {
 	nodes []Node
+	// The name of the file that the document was parsed from, if any.
+	filename string
+}
+
+// NewDoc returns a document of the nodes, e.g. to
+// print nodes that were created or modified in code.
+func NewDoc(nodes []Node) Doc {
+	return Doc{nodes: nodes}
 }
 
 func (d Doc) Nodes() []Node {
 	return d.nodes
 }
+
+// Filename returns the name of the file that the document was
+// parsed from, e.g. by ParseFile, or "" if it was not parsed
+// from a file.
+func (d Doc) Filename() string {
+	return d.filename
+}
@@ -0,0 +1,393 @@
+package gokdl
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Marshaler is implemented by types that encode themselves
+// as a node. The name of the node is set by the encoder,
+// e.g. to the name of the struct field.
+type Marshaler interface {
+	MarshalKDL() (Node, error)
+}
+
+// ValueMarshaler is implemented by types that encode themselves
+// as a value, i.e. an argument or the value of a property.
+// The type annotation of the argument is used as the type
+// annotation of a property value.
+type ValueMarshaler interface {
+	MarshalKDLValue() (Arg, error)
+}
+
+// Marshal returns v encoded as a KDL document.
+//
+// It is the inverse of Unmarshal: v is encoded like the children of
+// a node, using the same struct tags. Types that implement Marshaler
+// and ValueMarshaler encode themselves, and an encoding.TextMarshaler
+// is encoded as a string value. Nil pointers are omitted, except as
+// arguments, which are null. The entries of maps are encoded in the
+// order of their keys. A Node is encoded as itself. Values that
+// contain themselves, e.g. through a pointer, can not be encoded.
+func Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("can not encode a nil value as a document")
+	}
+	es := &encodeState{seen: map[seenKey]bool{}}
+	root, err := es.node("", rv)
+	if err != nil {
+		return nil, err
+	}
+	if len(root.Args) > 0 || len(root.Props) > 0 {
+		return nil, fmt.Errorf("can not encode %T as a document: it has arguments or properties", v)
+	}
+	return []byte(NewDoc(root.Children).String()), nil
+}
+
+type encodeState struct {
+	// The pointers and maps that the value being
+	// encoded is in, to report cycles as errors.
+	seen map[seenKey]bool
+}
+
+type seenKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// enter records that the pointer or map v is being encoded,
+// and returns an error if it already is, i.e. if it is in a cycle.
+// The caller must call leave when v is encoded.
+func (es *encodeState) enter(v reflect.Value) error {
+	key := seenKey{v.Pointer(), v.Type()}
+	if es.seen[key] {
+		return fmt.Errorf("can not encode a cycle via %s", v.Type())
+	}
+	es.seen[key] = true
+	return nil
+}
+
+func (es *encodeState) leave(v reflect.Value) {
+	delete(es.seen, seenKey{v.Pointer(), v.Type()})
+}
+
+// node returns v encoded as a node with the name.
+func (es *encodeState) node(name string, v reflect.Value) (Node, error) {
+	n := Node{Name: name, Children: []Node{}, Props: []Prop{}, Args: []Arg{}}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return n, nil
+		}
+		if m, ok := v.Interface().(Marshaler); ok {
+			return marshalNode(name, m)
+		}
+		if v.Kind() == reflect.Pointer {
+			if err := es.enter(v); err != nil {
+				return n, encodeError(name, err)
+			}
+			defer es.leave(v)
+		}
+		v = v.Elem()
+	}
+
+	if m, ok := marshaler[Marshaler](v); ok {
+		return marshalNode(name, m)
+	}
+	if v.Type() == nodeType {
+		n = v.Interface().(Node)
+		n.Name = name
+		return n, nil
+	}
+
+	if isEncodedValue(v) {
+		arg, err := encodeValue(v)
+		if err != nil {
+			return n, encodeError(name, err)
+		}
+		n.Args = append(n.Args, arg)
+		return n, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return es.structFields(n, v)
+	case reflect.Map:
+		if err := es.enter(v); err != nil {
+			return n, encodeError(name, err)
+		}
+		defer es.leave(v)
+		children, err := es.mapNodes(v)
+		n.Children = children
+		return n, err
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			arg, err := encodeValue(v.Index(i))
+			if err != nil {
+				return n, encodeError(name, err)
+			}
+			n.Args = append(n.Args, arg)
+		}
+		return n, nil
+	}
+	return n, encodeError(name, fmt.Errorf("can not encode %s as a node", v.Type()))
+}
+
+func marshalNode(name string, m Marshaler) (Node, error) {
+	n, err := m.MarshalKDL()
+	if err != nil {
+		return n, encodeError(name, err)
+	}
+	n.Name = name
+	return n, nil
+}
+
+func (es *encodeState) structFields(n Node, v reflect.Value) (Node, error) {
+	for _, f := range structFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			// The field is in a nil embedded struct
+			continue
+		}
+
+		switch f.kind {
+		case kindArg:
+			arg, err := encodeValue(fv)
+			if err != nil {
+				return n, encodeError(f.goName, err)
+			}
+			n.Args = append(n.Args, arg)
+		case kindArgs:
+			if k := indirectType(fv.Type()).Kind(); k != reflect.Slice && k != reflect.Array {
+				return n, encodeError(f.goName, fmt.Errorf("can not encode %s into arguments", fv.Type()))
+			}
+			// A nil pointer to the arguments is no arguments
+			if fv = indirect(fv); isNil(fv) {
+				continue
+			}
+			for i := 0; i < fv.Len(); i++ {
+				arg, err := encodeValue(fv.Index(i))
+				if err != nil {
+					return n, encodeError(f.goName, err)
+				}
+				n.Args = append(n.Args, arg)
+			}
+		case kindProp:
+			if isNil(fv) {
+				continue
+			}
+			arg, err := encodeValue(fv)
+			if err != nil {
+				return n, encodeError(f.goName, err)
+			}
+			n.Props = append(n.Props, Prop{Name: f.name, Value: arg.Value, ValueTypeAnnot: arg.TypeAnnotation})
+		case kindProps:
+			if t := indirectType(fv.Type()); t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+				return n, encodeError(f.goName, fmt.Errorf("can not encode %s into properties", fv.Type()))
+			}
+			fv = indirect(fv)
+			if isNil(fv) {
+				continue
+			}
+			for _, k := range sortedKeys(fv) {
+				arg, err := encodeValue(fv.MapIndex(k))
+				if err != nil {
+					return n, encodeError(f.goName, err)
+				}
+				n.Props = append(n.Props, Prop{Name: k.String(), Value: arg.Value, ValueTypeAnnot: arg.TypeAnnotation})
+			}
+		case kindChild:
+			if isNil(fv) {
+				continue
+			}
+			if fv.Kind() == reflect.Slice && !isEncodedValue(fv) {
+				for i := 0; i < fv.Len(); i++ {
+					child, err := es.node(f.name, fv.Index(i))
+					if err != nil {
+						return n, err
+					}
+					n.Children = append(n.Children, child)
+				}
+				continue
+			}
+			child, err := es.node(f.name, fv)
+			if err != nil {
+				return n, err
+			}
+			n.Children = append(n.Children, child)
+		case kindChildren:
+			switch indirectType(fv.Type()).Kind() {
+			case reflect.Map, reflect.Slice, reflect.Array:
+			default:
+				return n, encodeError(f.goName, fmt.Errorf("can not encode %s into children", fv.Type()))
+			}
+			fv = indirect(fv)
+			if isNil(fv) {
+				continue
+			}
+
+			var children []Node
+			var err error
+			if fv.Kind() == reflect.Map {
+				children, err = es.mapNodes(fv)
+			} else {
+				for i := 0; i < fv.Len() && err == nil; i++ {
+					var child Node
+					child, err = es.node("", fv.Index(i))
+					children = append(children, child)
+				}
+			}
+			if err != nil {
+				return n, err
+			}
+			n.Children = append(n.Children, children...)
+		case kindName:
+			if fv.Kind() != reflect.String {
+				return n, fmt.Errorf("can not encode the name of a node from field %s of type %s", f.goName, fv.Type())
+			}
+			if name := fv.String(); name != "" {
+				n.Name = name
+			}
+		default:
+			return n, fmt.Errorf("invalid kind %q in the tag of field %s", f.kind, f.goName)
+		}
+	}
+	return n, nil
+}
+
+// mapNodes returns the entries of the map v
+// encoded as nodes named by their keys.
+func (es *encodeState) mapNodes(v reflect.Value) ([]Node, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("can not encode %s as nodes: the keys are not strings", v.Type())
+	}
+
+	nodes := []Node{}
+	for _, k := range sortedKeys(v) {
+		n, err := es.node(k.String(), v.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// encodeValue returns v encoded as a value.
+func encodeValue(v reflect.Value) (Arg, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return Arg{}, nil
+		}
+		if m, ok := v.Interface().(ValueMarshaler); ok {
+			return m.MarshalKDLValue()
+		}
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			return marshalText(m)
+		}
+		v = v.Elem()
+	}
+
+	if m, ok := marshaler[ValueMarshaler](v); ok {
+		return m.MarshalKDLValue()
+	}
+	if m, ok := marshaler[encoding.TextMarshaler](v); ok {
+		return marshalText(m)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return Arg{Value: v.String()}, nil
+	case reflect.Bool:
+		return Arg{Value: v.Bool()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Arg{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Arg{Value: v.Uint()}, nil
+	case reflect.Float32, reflect.Float64:
+		return Arg{Value: v.Float()}, nil
+	}
+	return Arg{}, fmt.Errorf("can not encode %s as a value", v.Type())
+}
+
+func marshalText(m encoding.TextMarshaler) (Arg, error) {
+	text, err := m.MarshalText()
+	if err != nil {
+		return Arg{}, err
+	}
+	return Arg{Value: string(text)}, nil
+}
+
+// marshaler returns v, or a pointer to v if it is addressable, as a T.
+func marshaler[T any](v reflect.Value) (T, bool) {
+	if !v.CanInterface() {
+		var zero T
+		return zero, false
+	}
+	if m, ok := v.Interface().(T); ok {
+		return m, true
+	}
+	if v.CanAddr() {
+		m, ok := v.Addr().Interface().(T)
+		return m, ok
+	}
+	var zero T
+	return zero, false
+}
+
+// isEncodedValue reports whether v is encoded as a single value.
+func isEncodedValue(v reflect.Value) bool {
+	for v.Kind() == reflect.Pointer {
+		v = reflect.New(v.Type().Elem()).Elem()
+	}
+	if _, ok := marshaler[ValueMarshaler](v); ok {
+		return true
+	}
+	if _, ok := marshaler[encoding.TextMarshaler](v); ok {
+		return true
+	}
+	return isBasic(v)
+}
+
+// indirect returns the value that v points to, through any
+// number of pointers, or the first nil pointer.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// indirectType returns the type that t points to,
+// through any number of pointers.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+func encodeError(name string, err error) error {
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
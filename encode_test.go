@@ -0,0 +1,203 @@
+package gokdl
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	// Arrange
+	warn := LogLevel(2)
+	config := testConfig{
+		Debug:   true,
+		Workers: 4,
+		Hosts:   []string{"a.example.com", "b.example.com"},
+		Servers: []testServer{
+			{
+				Name:     "api",
+				Port:     8080,
+				Timeout:  Duration(90 * time.Second),
+				MaxBody:  1024,
+				Level:    &warn,
+				Labels:   map[string]string{"zone": "eu", "tier": "1"},
+				Upstream: []Endpoint{{"10.0.0.1:9000"}},
+				TLS:      &testTLS{Cert: "api.pem", Key: "api.key"},
+			},
+			{Name: "admin", Port: 9090},
+		},
+		Env:     map[string]string{"PATH": "/bin", "HOME": "/root"},
+		Ignored: "x",
+	}
+
+	// Act
+	bs, err := Marshal(&config)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, `Debug true
+Workers 4
+host "a.example.com"
+host "b.example.com"
+server "api" port=8080 timeout="1m30s" max-body=1024 level="warn" tier="1" zone="eu" {
+    upstream "10.0.0.1" 9000
+    tls cert="api.pem" key="api.key"
+}
+server "admin" port=9090 timeout="0s" max-body=0
+env {
+    HOME "/root"
+    PATH "/bin"
+}
+`, string(bs))
+
+	var decoded testConfig
+	require.NoError(t, Unmarshal(bs, &decoded))
+	config.Ignored = ""
+	config.Servers[1].Labels = map[string]string{}
+	require.Equal(t, config, decoded)
+}
+
+func TestMarshalKinds(t *testing.T) {
+	type item struct {
+		Name  string `kdl:",name"`
+		Value int    `kdl:",arg"`
+	}
+	type node struct {
+		First    string  `kdl:",arg"`
+		Rest     []any   `kdl:",args"`
+		Items    []item  `kdl:",children"`
+		Raw      Node    `kdl:"raw"`
+		Optional *string `kdl:"optional,prop"`
+		Nil      *item   `kdl:"nil"`
+	}
+	n := node{
+		First: "a",
+		Rest:  []any{1, 2.5, nil, uint8(3)},
+		Items: []item{{"c", 3}, {"d", 4}},
+		Raw:   Node{Name: "ignored", Args: []Arg{{Value: int64(1), TypeAnnotation: U8}}},
+	}
+
+	// Act
+	bs, err := Marshal(map[string]node{"n": n})
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, `n "a" 1 2.5 null 3 {
+    c 3
+    d 4
+    raw (u8)1
+}
+`, string(bs))
+}
+
+func TestMarshalArgsPointer(t *testing.T) {
+	type node struct {
+		Args *[]int `kdl:",args"`
+	}
+	args := []int{1, 2}
+
+	// Act
+	bs, err := Marshal(map[string]node{"a": {Args: &args}, "b": {}})
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "a 1 2\nb\n", string(bs))
+}
+
+func TestMarshalEmbeddedPointer(t *testing.T) {
+	type Embedded struct {
+		X int `kdl:"x,prop"`
+	}
+	type node struct {
+		*Embedded
+		Y int `kdl:"y,prop"`
+	}
+
+	// Act
+	bs, err := Marshal(map[string]node{"a": {Embedded: &Embedded{X: 1}, Y: 2}, "b": {Y: 3}})
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "a x=1 y=2\nb y=3\n", string(bs))
+}
+
+func TestMarshalSharedPointer(t *testing.T) {
+	type node struct {
+		V int `kdl:",arg"`
+	}
+	shared := &node{V: 1}
+
+	// Act
+	bs, err := Marshal(map[string]*node{"a": shared, "b": shared})
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "a 1\nb 1\n", string(bs))
+}
+
+type cycle struct {
+	Next *cycle `kdl:"next"`
+}
+
+func newCycle() *cycle {
+	c := &cycle{}
+	c.Next = &cycle{Next: c}
+	return c
+}
+
+func mapCycle() map[string]any {
+	m := map[string]any{}
+	m["a"] = m
+	return m
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalKDL() (Node, error) {
+	return Node{}, errors.New("failed")
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []struct {
+		testname string
+		value    any
+		message  string
+	}{
+		{"value", 1, "can not encode int as a document: it has arguments or properties"},
+		{"marshaler", map[string]failingMarshaler{"node": {}}, "node: failed"},
+		{"value type", struct {
+			C chan int `kdl:"c,prop"`
+		}{}, "C: can not encode chan int as a value"},
+		{"map keys", map[int]string{}, "can not encode map[int]string as nodes: the keys are not strings"},
+		{"nil", nil, "can not encode a nil value as a document"},
+		{"args kind", map[string]struct {
+			A int `kdl:",args"`
+		}{"n": {}}, "A: can not encode int into arguments"},
+		{"props kind", map[string]struct {
+			P int `kdl:",props"`
+		}{"n": {}}, "P: can not encode int into properties"},
+		{"props keys", map[string]struct {
+			P map[int]string `kdl:",props"`
+		}{"n": {}}, "P: can not encode map[int]string into properties"},
+		{"children kind", map[string]struct {
+			C struct{} `kdl:",children"`
+		}{"n": {}}, "C: can not encode struct {} into children"},
+		{"name kind", map[string]struct {
+			N int `kdl:",name"`
+		}{"n": {}}, "can not encode the name of a node from field N of type int"},
+		{"pointer cycle", newCycle(), "next: can not encode a cycle via *gokdl.cycle"},
+		{"map cycle", mapCycle(), "a: can not encode a cycle via map[string]interface {}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			_, err := Marshal(test.value)
+
+			// Assert
+			require.EqualError(t, err, test.message)
+		})
+	}
+}
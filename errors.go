@@ -0,0 +1,56 @@
+package gokdl
+
+import (
+	"fmt"
+
+	pkg "github.com/lunjon/gokdl/internal"
+)
+
+// Position describes a location in a KDL document.
+type Position struct {
+	// Filename is the name of the file, if any,
+	// e.g. of a file included by another document.
+	Filename string
+	// Byte offset, starting at 0.
+	Offset int
+	// Line number, starting at 1.
+	Line int
+	// Column number, starting at 1.
+	// The column is counted in runes, not bytes.
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+func newPosition(p pkg.Position, filename string) Position {
+	return Position{
+		Filename: filename,
+		Offset:   p.Offset,
+		Line:     p.Line,
+		Column:   p.Column,
+	}
+}
+
+// ParseError is the error returned when a document
+// could not be parsed. It wraps the underlying error,
+// e.g. the error of a cancelled context, so it can be
+// inspected using errors.Is and errors.As.
+type ParseError struct {
+	// Pos is the position in the document
+	// at which the error was detected.
+	Pos Position
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
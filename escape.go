@@ -0,0 +1,127 @@
+package gokdl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pkg "github.com/lunjon/gokdl/internal"
+)
+
+// The runes of the escapes \n, \r etc.
+var escapes = map[rune]rune{
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'\\': '\\',
+	'/':  '/',
+	'"':  '"',
+	'b':  '\b',
+	'f':  '\f',
+	's':  ' ',
+}
+
+// Maximum number of hex digits in a unicode escape, e.g. \u{10FFFF}.
+const maxUnicodeEscapeDigits = 6
+
+// unescape returns s with its escape sequences decoded.
+func unescape(s string) (string, error) {
+	sc := pkg.NewScannerString(context.Background(), s)
+	buf := strings.Builder{}
+	for {
+		ch := sc.ScanRune()
+		if ch == pkg.EOF_RUNE {
+			return buf.String(), nil
+		} else if ch != '\\' {
+			buf.WriteRune(ch)
+		} else if err := scanEscape(sc, &buf); err != nil {
+			return "", err
+		}
+	}
+}
+
+// scanEscape scans the escape sequence after a backslash
+// in a string and writes the rune it represents to buf.
+//
+// A backslash followed by whitespace, including newlines,
+// is a whitespace escape: the whitespace is skipped.
+func scanEscape(sc *pkg.Scanner, buf *strings.Builder) error {
+	ch := sc.ScanRune()
+	if r, ok := escapes[ch]; ok {
+		buf.WriteRune(r)
+		return nil
+	}
+
+	switch {
+	case ch == pkg.EOF_RUNE:
+		return fmt.Errorf("error reading string literal: reached EOF")
+	case ch == 'u':
+		r, err := scanUnicodeEscape(sc)
+		if err != nil {
+			return err
+		}
+		buf.WriteRune(r)
+		return nil
+	case unicode.IsSpace(ch):
+		for unicode.IsSpace(ch) {
+			ch = sc.ScanRune()
+		}
+		if ch != pkg.EOF_RUNE {
+			sc.UnscanRune()
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid escape sequence in string: \\%c", ch)
+	}
+}
+
+// scanUnicodeEscape scans the {X} part of a unicode escape \u{X},
+// where X is 1 to 6 hex digits that must be a Unicode scalar value.
+func scanUnicodeEscape(sc *pkg.Scanner) (rune, error) {
+	if sc.ScanRune() != '{' {
+		return 0, fmt.Errorf("invalid unicode escape in string: expected \\u{X}")
+	}
+
+	var digits strings.Builder
+	for {
+		ch := sc.ScanRune()
+		if ch == '}' {
+			break
+		}
+		if !isHexDigit(ch) {
+			return 0, fmt.Errorf("invalid unicode escape in string: expected hex digit or }")
+		}
+		digits.WriteRune(ch)
+	}
+
+	hex := digits.String()
+	if hex == "" || len(hex) > maxUnicodeEscapeDigits {
+		return 0, fmt.Errorf("invalid unicode escape in string: \\u{%s} must have 1 to %d hex digits", hex, maxUnicodeEscapeDigits)
+	}
+
+	var r rune
+	for _, d := range hex {
+		r = r<<4 | hexValue(d)
+	}
+	if !utf8.ValidRune(r) {
+		return 0, fmt.Errorf("invalid unicode escape in string: \\u{%s} is not a Unicode scalar value", hex)
+	}
+	return r, nil
+}
+
+func isHexDigit(r rune) bool {
+	return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
+
+func hexValue(r rune) rune {
+	switch {
+	case '0' <= r && r <= '9':
+		return r - '0'
+	case 'a' <= r && r <= 'f':
+		return r - 'a' + 10
+	default:
+		return r - 'A' + 10
+	}
+}
@@ -0,0 +1,57 @@
+package gokdl
+
+// Event is an item of a document read by a Reader.
+//
+// The events of a node are always in the following order:
+// StartNode, the arguments and properties (Arg and Prop) in the order
+// they appear, any children between StartChildren and EndChildren,
+// and finally EndNode. Comments can appear between any events.
+//
+// Items commented out using slash-dash are not read, unless
+// the reader is created with the RetainSlashDash option.
+type Event interface {
+	isEvent()
+}
+
+// StartNode starts a node and is followed
+// by the events of the node until EndNode.
+type StartNode struct {
+	Name string
+	// Type annotation on the node.
+	// It has the zero value if no type annotation
+	// exists for this node.
+	TypeAnnotation TypeAnnotation
+}
+
+// EndNode ends the node of the last StartNode not yet ended.
+type EndNode struct{}
+
+// StartChildren starts the children block of a node.
+// The children are the nodes up until EndChildren.
+type StartChildren struct{}
+
+// EndChildren ends the children block of a node.
+type EndChildren struct{}
+
+// Comment is a line comment or a multiline comment.
+type Comment struct {
+	// Text of the comment, including the
+	// comment markers, i.e. // or /* and */.
+	Text string
+}
+
+// SlashDash marks that the following item is commented out using
+// slash-dash, i.e. /-. The item is the next Arg or Prop, or the events
+// from the next StartNode or StartChildren up to and including its
+// matching EndNode or EndChildren. It is only read by a reader
+// created with the RetainSlashDash option.
+type SlashDash struct{}
+
+func (Arg) isEvent()           {}
+func (Prop) isEvent()          {}
+func (StartNode) isEvent()     {}
+func (EndNode) isEvent()       {}
+func (StartChildren) isEvent() {}
+func (EndChildren) isEvent()   {}
+func (Comment) isEvent()       {}
+func (SlashDash) isEvent()     {}
@@ -0,0 +1,249 @@
+package gokdl
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fieldKind is what a struct field is decoded from,
+// and encoded to, as given by its tag.
+type fieldKind string
+
+const (
+	// The next argument of the node.
+	kindArg fieldKind = "arg"
+	// The remaining arguments of the node, into a slice.
+	kindArgs fieldKind = "args"
+	// The property with the name of the field.
+	kindProp fieldKind = "prop"
+	// The properties that no other field is decoded from, into a map.
+	kindProps fieldKind = "props"
+	// The child node with the name of the field, or every
+	// child node with the name if the field is a slice.
+	kindChild fieldKind = "child"
+	// The child nodes that no other field is decoded
+	// from, into a slice or into a map by name.
+	kindChildren fieldKind = "children"
+	// The name of the node.
+	kindName fieldKind = "name"
+)
+
+var fieldKinds = map[fieldKind]bool{
+	kindArg:      true,
+	kindArgs:     true,
+	kindProp:     true,
+	kindProps:    true,
+	kindChild:    true,
+	kindChildren: true,
+	kindName:     true,
+}
+
+// field is a struct field that is decoded and encoded.
+type field struct {
+	name string
+	// The name of the field in Go.
+	goName string
+	kind   fieldKind
+	// Index of the field, see reflect.Value.FieldByIndex.
+	index []int
+	// The name is matched ignoring case if the
+	// field has no name in its tag, like encoding/json.
+	foldName bool
+	// The item must be present when decoding.
+	required bool
+	// The value when the item is not present when decoding.
+	def *Arg
+	// The constraints that the decoded value is validated by.
+	constraints constraints
+	// An invalid option in the tag, reported when decoding.
+	err error
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// structFields returns the fields of the struct type t, with the
+// fields of embedded structs, or pointers to them, without a tag
+// in their place.
+//
+// The tag of a field is `kdl:"name,kind,options..."`, where every
+// part is optional. The name defaults to the name of the field and
+// the kind to child. The kind may be left out before the options,
+// e.g. `kdl:"tls,required"`. The pattern option is the rest of the
+// tag, so that it may contain commas. Fields with the tag `kdl:"-"`
+// are ignored, as are unexported fields.
+func structFields(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+
+	fields := typeFields(t, map[reflect.Type]bool{})
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// typeFields returns the fields of the struct type t, see structFields.
+// The embedded structs that t is embedded in are in outer, so that
+// a struct embedding a pointer to itself is not flattened forever.
+func typeFields(t reflect.Type, outer map[reflect.Type]bool) []field {
+	outer[t] = true
+	defer delete(outer, t)
+
+	fields := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("kdl")
+		if tag == "-" {
+			continue
+		}
+
+		if embedded, ok := embeddedStruct(sf); ok && !hasTag && !outer[embedded] {
+			for _, f := range typeFields(embedded, outer) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := field{name: sf.Name, goName: sf.Name, kind: kindChild, index: []int{i}, foldName: true}
+		name, rest, _ := strings.Cut(tag, ",")
+		if name != "" {
+			f.name = name
+			f.foldName = false
+		}
+		parts := strings.Split(rest, ",")
+		for j, part := range parts {
+			if part == "" {
+				continue
+			}
+			if j == 0 && fieldKinds[fieldKind(part)] {
+				f.kind = fieldKind(part)
+				continue
+			}
+			if key, _, _ := strings.Cut(part, "="); key == "pattern" {
+				part = strings.Join(parts[j:], ",")
+				f.err = f.option(part)
+				break
+			}
+			if err := f.option(part); err != nil {
+				if j == 0 && !strings.Contains(part, "=") {
+					// An invalid kind is reported when decoding and encoding
+					f.kind = fieldKind(part)
+					continue
+				}
+				f.err = err
+				break
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// embeddedStruct returns the type of the struct that is embedded as
+// the field sf, and false if sf is not an embedded struct. Pointers
+// to unexported structs are not embedded, as they can not be
+// allocated when decoding.
+func embeddedStruct(sf reflect.StructField) (reflect.Type, bool) {
+	if !sf.Anonymous {
+		return nil, false
+	}
+	t := sf.Type
+	if t.Kind() == reflect.Pointer {
+		if !sf.IsExported() {
+			return nil, false
+		}
+		t = t.Elem()
+	}
+	return t, t.Kind() == reflect.Struct
+}
+
+// fieldByIndex returns the field at index of the struct v. The
+// nil pointers to embedded structs on the way are allocated if
+// alloc is true, and otherwise it returns false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// option sets the option of the tag, e.g. "required" or "min=1".
+func (f *field) option(option string) error {
+	key, value, hasValue := strings.Cut(option, "=")
+	if key == "required" && !hasValue {
+		f.required = true
+		return nil
+	}
+	if key == "" || !hasValue {
+		return fmt.Errorf("invalid option %q in the tag of field %s", option, f.goName)
+	}
+
+	var err error
+	switch key {
+	case "default":
+		def := parseDefault(value)
+		f.def = &def
+	case "min":
+		f.constraints.min, err = parseBound(value)
+	case "max":
+		f.constraints.max, err = parseBound(value)
+	case "oneof":
+		f.constraints.oneof = strings.Fields(value)
+	case "pattern":
+		f.constraints.pattern, err = regexp.Compile(value)
+	default:
+		return fmt.Errorf("invalid option %q in the tag of field %s", option, f.goName)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid option %q in the tag of field %s: %w", option, f.goName, err)
+	}
+	return nil
+}
+
+// parseDefault returns the value of a default option, which is a
+// value like in a document, e.g. 8080 or true. Anything else is
+// a string, so that e.g. debug and 1m30s need no quotes.
+func parseDefault(s string) Arg {
+	doc, err := ParseString("_ " + s)
+	if err != nil || strings.TrimSpace(s) != s {
+		return Arg{Value: s}
+	}
+	nodes := doc.Nodes()
+	if len(nodes) != 1 || len(nodes[0].Args) != 1 || len(nodes[0].Props) > 0 || len(nodes[0].Children) > 0 {
+		return Arg{Value: s}
+	}
+	return nodes[0].Args[0]
+}
+
+func parseBound(s string) (*float64, error) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// matches reports whether the name of a
+// property or node matches the field.
+func (f field) matches(name string) bool {
+	if f.foldName {
+		return strings.EqualFold(f.name, name)
+	}
+	return f.name == name
+}
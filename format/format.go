@@ -0,0 +1,295 @@
+// Package format implements the canonical formatting of KDL documents.
+//
+// The formatting is opinionated and has no options:
+//   - every node is on its own line, indented by four spaces per level
+//   - children blocks open on the line of the node and close on their own line
+//   - items of a node are separated by a single space, without
+//     spaces around = or after type annotations and slash-dashes
+//   - line continuations are joined into a single line, unless
+//     needed to keep a comment
+//   - node names, property names and type annotations are
+//     written as bare identifiers when possible
+//   - at most one blank line is kept between nodes
+//
+// Comments are kept and nothing is reordered, so the formatted
+// document is equal to the original when parsed.
+package format
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/lunjon/gokdl"
+	pkg "github.com/lunjon/gokdl/internal"
+	"github.com/lunjon/gokdl/lexer"
+)
+
+const indentation = "    "
+
+// Source formats the KDL document in src and returns the result.
+// An error is returned if src is not a valid document.
+// Formatting the result again returns it unchanged.
+func Source(src []byte) ([]byte, error) {
+	if _, err := gokdl.ParseBytes(src); err != nil {
+		return nil, err
+	}
+
+	// The EOF token ends the tokens so that looking ahead never fails
+	tokens := lexer.Tokenize(string(src))
+	tokens = append(tokens, lexer.Token{Kind: lexer.EOF, Start: len(src), End: len(src)})
+
+	f := &formatter{
+		tokens:     tokens,
+		nodeStart:  true,
+		blockStart: true,
+	}
+	f.format()
+	return []byte(f.out.String()), nil
+}
+
+type formatter struct {
+	tokens []lexer.Token
+	out    strings.Builder
+	// The current line, without indentation.
+	line  strings.Builder
+	depth int
+	// The current line continues the node of the previous line.
+	continued bool
+	// The next item is written without a space before it.
+	glue bool
+	// The next name is the name of a node.
+	nodeStart bool
+	// No line has been written in the current block yet.
+	blockStart bool
+	// Number of newlines since the last item.
+	newlines int
+	// A blank line is written before the next line.
+	blank bool
+	// Newlines until the next item continue the current node.
+	join bool
+}
+
+func (f *formatter) format() {
+	for i := 0; i < len(f.tokens); i++ {
+		tok := f.tokens[i]
+		switch tok.Kind {
+		case lexer.Whitespace, lexer.Newline, lexer.EOF:
+		default:
+			f.newlines = 0
+			f.join = false
+			if tok.Text != "{" && tok.Text != "}" {
+				f.glue = f.glue || f.adjacent(i)
+			}
+		}
+
+		switch tok.Kind {
+		case lexer.Whitespace, lexer.EOF:
+		case lexer.Newline:
+			if f.join {
+				continue
+			}
+			f.newlines++
+			if f.newlines > 1 && !f.blockStart {
+				f.blank = true
+			}
+			f.endNode()
+		case lexer.Punctuation:
+			i = f.punctuation(i)
+		case lexer.Comment:
+			f.comment(tok)
+		case lexer.Annotation:
+			f.add("(" + name(tok.Text[1:len(tok.Text)-1]) + ")")
+			f.glue = true
+		case lexer.Identifier, lexer.String, lexer.RawString:
+			isName := f.nodeStart || f.tokens[f.next(i, false)].Text == "="
+			if isName && !f.afterValue(i) {
+				f.add(name(tok.Text))
+			} else {
+				f.add(tok.Text)
+			}
+			f.nodeStart = false
+		default:
+			f.add(tok.Text)
+			f.nodeStart = false
+		}
+	}
+
+	f.endNode()
+}
+
+// adjacent reports whether the token at i directly follows another
+// item. The parser accepts some items without whitespace between them,
+// e.g. two strings, so they are kept together to not change the meaning.
+func (f *formatter) adjacent(i int) bool {
+	if i == 0 {
+		return false
+	}
+	switch prev := f.tokens[i-1]; prev.Kind {
+	case lexer.Whitespace, lexer.Newline:
+		return false
+	case lexer.Punctuation:
+		return prev.Text != ";" && prev.Text != "{" && prev.Text != "}" && prev.Text != `\`
+	}
+	return true
+}
+
+// afterValue reports whether the token at i directly follows a
+// value, in which case a quoted name is kept quoted so that
+// it is not joined with the value.
+func (f *formatter) afterValue(i int) bool {
+	if i == 0 {
+		return false
+	}
+	switch f.tokens[i-1].Kind {
+	case lexer.Identifier, lexer.String, lexer.RawString, lexer.Number, lexer.Keyword, lexer.Invalid:
+		return true
+	}
+	return false
+}
+
+// punctuation formats the punctuation at i and
+// returns the index of the last token consumed.
+func (f *formatter) punctuation(i int) int {
+	tok := f.tokens[i]
+	switch tok.Text {
+	case ";":
+		f.endNode()
+	case "=":
+		f.glue = true
+		f.add("=")
+		f.glue = true
+	case "{":
+		if next := f.next(i, true); f.tokens[next].Text == "}" {
+			f.add("{}")
+			return next
+		}
+
+		f.add("{")
+		if next := f.next(i, false); isLineComment(f.tokens[next]) {
+			f.comment(f.tokens[next])
+			i = next
+		}
+		f.endLine()
+		f.depth++
+		f.nodeStart = true
+		f.blockStart = true
+		f.blank = false
+	case "}":
+		f.endLine()
+		f.depth--
+		f.continued = false
+		f.blank = false
+		f.add("}")
+		f.nodeStart = false
+	case `\`:
+		next := f.next(i, false)
+		if isLineComment(f.tokens[next]) {
+			// The continuation is needed to keep the comment
+			f.add(tok.Text)
+			f.comment(f.tokens[next])
+			f.endLine()
+			f.continued = true
+			f.join = true
+			return next
+		}
+		// Join the next lines with the current line
+		f.join = true
+		f.glue = false
+	default:
+		f.add(tok.Text)
+	}
+	return i
+}
+
+func (f *formatter) comment(tok lexer.Token) {
+	switch {
+	case tok.Text == "/-":
+		// The item that is commented out can be on a following line
+		f.add(tok.Text)
+		f.glue = true
+		f.join = true
+	case isLineComment(tok):
+		f.add(strings.TrimRightFunc(tok.Text, unicode.IsSpace))
+	default:
+		f.add(tok.Text)
+	}
+}
+
+// next returns the index of the next token after i that is
+// not whitespace, or newlines if skipNewlines is true.
+func (f *formatter) next(i int, skipNewlines bool) int {
+	for i++; i < len(f.tokens)-1; i++ {
+		switch f.tokens[i].Kind {
+		case lexer.Whitespace:
+		case lexer.Newline:
+			if !skipNewlines {
+				return i
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// add adds an item to the current line.
+func (f *formatter) add(s string) {
+	if f.line.Len() > 0 && !f.glue {
+		f.line.WriteByte(' ')
+	}
+	f.glue = false
+	f.line.WriteString(s)
+}
+
+// endNode ends the line and the node on it.
+func (f *formatter) endNode() {
+	f.endLine()
+	f.continued = false
+	f.nodeStart = true
+}
+
+// endLine writes the current line, if any, to the output.
+func (f *formatter) endLine() {
+	if f.line.Len() == 0 {
+		return
+	}
+
+	if f.blank {
+		f.out.WriteByte('\n')
+		f.blank = false
+	}
+
+	depth := f.depth
+	if f.continued {
+		depth++
+	}
+	f.out.WriteString(strings.Repeat(indentation, depth))
+	f.out.WriteString(f.line.String())
+	f.out.WriteByte('\n')
+
+	f.line.Reset()
+	f.glue = false
+	f.blockStart = false
+}
+
+func isLineComment(tok lexer.Token) bool {
+	return tok.Kind == lexer.Comment && strings.HasPrefix(tok.Text, "//")
+}
+
+// name returns the name in the identifier or string as a bare
+// identifier if possible, otherwise it is returned unchanged.
+func name(s string) string {
+	if pkg.IsBareIdent(s) {
+		return s
+	}
+
+	// Decode the string by parsing it as the name of a node
+	doc, err := gokdl.ParseString(s)
+	if err != nil || len(doc.Nodes()) != 1 {
+		return s
+	}
+	if n := doc.Nodes()[0].Name; pkg.IsBareIdent(n) {
+		return n
+	}
+	return s
+}
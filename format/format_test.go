@@ -0,0 +1,116 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/lunjon/gokdl/lexer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"single node", "node", "node\n"},
+		{"whitespace", "  node   1  key=\"value\"   \n", "node 1 key=\"value\"\n"},
+		{"semicolons", "a; b;c;\n", "a\nb\nc\n"},
+		{"children", "parent { child1; child2 }", "parent {\n    child1\n    child2\n}\n"},
+		{"nested", "a {\nb {\nc\n}\n}", "a {\n    b {\n        c\n    }\n}\n"},
+		{"empty children", "node {\n\n}", "node {}\n"},
+		{"quoted names", `"node" "arg" "key"=1`, `node "arg" key=1` + "\n"},
+		{"raw string name", `r"node" r"arg"`, `node r"arg"` + "\n"},
+		{"names requiring quotes", `"two words" "true" "1a" "key word"=1`, `"two words" "true" "1a" "key word"=1` + "\n"},
+		{"type annotations", `(author)node (u8)1 (i32)key=(u8)1`, `(author)node (u8)1 (i32)key=(u8)1` + "\n"},
+		{"line continuation", "node 1 \\\n    2 \\\n\n  3\n", "node 1 2 3\n"},
+		{"line continuation with comment", "node 1 \\ // the rest\n  2 3\n", "node 1 \\ // the rest\n    2 3\n"},
+		{"line comments", "// first\nnode // trailing   \n  // last\n", "// first\nnode // trailing\n// last\n"},
+		{"block comments", "/* a */ node /* b */ 1\n/*\n  multi\n*/", "/* a */ node /* b */ 1\n/*\n  multi\n*/\n"},
+		{"comment after brace", "parent { // children\n child\n}", "parent { // children\n    child\n}\n"},
+		{"slash-dash", "/-node 1\nnode /-2 /-key=3 /-{\n  child\n}", "/-node 1\nnode /-2 /-key=3 /-{\n    child\n}\n"},
+		{"slash-dash with whitespace", "/- (t)node\n/-\n\"a b\"\nnode /- 1 /-\n{ child }", "/-(t)node\n/-\"a b\"\nnode /-1 /-{\n    child\n}\n"},
+		{"blank lines", "\n\na\n\n\n\nb\nc\n\n", "a\n\nb\nc\n"},
+		{"blank lines in block", "a {\n\n  b\n\n  c\n\n}", "a {\n    b\n\n    c\n}\n"},
+		{"multi-line string", "parent {\nchild \"\"\"\n      a\n        b\n      \"\"\" key=1\n}", "parent {\n    child \"\"\"\n      a\n        b\n      \"\"\" key=1\n}\n"},
+		{"crlf", "a {\r\n  b\r\n}\r\n", "a {\n    b\n}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			out, err := Source([]byte(test.src))
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, test.expected, string(out))
+		})
+	}
+}
+
+func TestSourceInvalid(t *testing.T) {
+	// Act
+	_, err := Source([]byte(`node "unterminated`))
+
+	// Assert
+	var perr *gokdl.ParseError
+	require.ErrorAs(t, err, &perr)
+}
+
+func TestSourceTestdata(t *testing.T) {
+	filenames, err := filepath.Glob("../testdata/*.kdl")
+	require.NoError(t, err)
+
+	for _, filename := range filenames {
+		t.Run(filepath.Base(filename), func(t *testing.T) {
+			bs, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			checkSource(t, bs)
+		})
+	}
+}
+
+func FuzzSource(f *testing.F) {
+	filenames, err := filepath.Glob("../testdata/*.kdl")
+	require.NoError(f, err)
+	for _, filename := range filenames {
+		bs, err := os.ReadFile(filename)
+		require.NoError(f, err)
+		f.Add(string(bs))
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		if _, err := gokdl.ParseString(src); err != nil {
+			return
+		}
+		for _, tok := range lexer.Tokenize(src) {
+			if tok.Kind == lexer.Invalid {
+				// The parser accepts some input that the lexer does
+				// not, so the tokens do not match the document
+				return
+			}
+		}
+		checkSource(t, []byte(src))
+	})
+}
+
+// checkSource checks that formatting src is idempotent
+// and results in a document equal to src.
+func checkSource(t *testing.T, src []byte) {
+	out, err := Source(src)
+	require.NoError(t, err)
+
+	again, err := Source(out)
+	require.NoError(t, err, "formatted:\n%s", out)
+	require.Equal(t, string(out), string(again), "not idempotent")
+
+	expected, err := gokdl.ParseBytes(src)
+	require.NoError(t, err)
+	actual, err := gokdl.ParseBytes(out)
+	require.NoError(t, err)
+	require.Equal(t, expected.Nodes(), actual.Nodes(), "formatted:\n%s", out)
+}
@@ -0,0 +1,64 @@
+package gokdl
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// include parses the file included by the directive n, which starts
+// at pos, and returns the builder of its nodes, which also has their
+// positions if positions is true.
+func (p *parser) include(n Node, pos Position, positions bool) (*treeBuilder, error) {
+	name, err := p.includePath(n)
+	if err != nil {
+		return nil, &ParseError{Pos: pos, Err: err}
+	}
+
+	chain := p.includes
+	if p.path != "" {
+		chain = append(append([]string{}, chain...), p.path)
+	}
+	for _, f := range chain {
+		if f == name {
+			cycle := strings.Join(append(chain, name), " -> ")
+			return nil, &ParseError{Pos: pos, Err: fmt.Errorf("include cycle: %s", cycle)}
+		}
+	}
+
+	src, err := fs.ReadFile(p.opts.includeFS, name)
+	if err != nil {
+		return nil, &ParseError{Pos: pos, Err: err}
+	}
+
+	parser := newParserString(p.ctx, string(src))
+	parser.opts = p.opts
+	parser.filename = name
+	parser.path = name
+	parser.includes = chain
+	return parser.build(positions)
+}
+
+// includePath returns the path in the file system
+// of the file included by the directive n.
+func (p *parser) includePath(n Node) (string, error) {
+	var name string
+	if len(n.Args) == 1 {
+		name, _ = n.Args[0].Value.(string)
+	}
+	if name == "" || len(n.Props) > 0 || len(n.Children) > 0 {
+		return "", fmt.Errorf("%s must have a single argument: the path of a file", n.Name)
+	}
+
+	var resolved string
+	if strings.HasPrefix(name, "/") {
+		resolved = path.Clean(name[1:])
+	} else {
+		resolved = path.Join(path.Dir(p.path), name)
+	}
+	if !fs.ValidPath(resolved) || resolved == "." {
+		return "", fmt.Errorf("invalid path of included file: %q", name)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,170 @@
+package gokdl
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludeFiles(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"server.kdl":      {Data: []byte("server port=8080 {\n    include \"tls/tls.kdl\"\n}")},
+		"tls/tls.kdl":     {Data: []byte("tls enabled=true\ninclude \"certs.kdl\"")},
+		"tls/certs.kdl":   {Data: []byte("cert \"a.pem\"\ncert \"b.pem\"")},
+		"logging.kdl":     {Data: []byte("/-include \"missing.kdl\"\nlogging level=\"warn\"")},
+		"empty/empty.kdl": {Data: []byte("// Nothing here")},
+	}
+	src := `name "app"
+include "server.kdl"
+include "/logging.kdl"
+include "empty/empty.kdl"
+other`
+
+	// Act
+	doc, err := ParseString(src, IncludeFiles(fsys))
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, `name "app"
+server port=8080 {
+    tls enabled=true
+    cert "a.pem"
+    cert "b.pem"
+}
+logging level="warn"
+other
+`, doc.String())
+}
+
+func TestIncludeNode(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"a.kdl": {Data: []byte("a")},
+	}
+	src := "import \"a.kdl\"\ninclude \"b.kdl\""
+
+	// Act
+	doc, err := ParseString(src, IncludeFiles(fsys), IncludeNode("import"))
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "a\ninclude \"b.kdl\"\n", doc.String())
+}
+
+func TestIncludeDisabled(t *testing.T) {
+	// Act
+	doc, err := ParseString(`include "a.kdl"`)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "include \"a.kdl\"\n", doc.String())
+}
+
+func TestIncludeInvalid(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.kdl":       {Data: []byte("a\ninclude \"sub/b.kdl\"")},
+		"sub/b.kdl":   {Data: []byte("b\n  include \"../a.kdl\"")},
+		"self.kdl":    {Data: []byte("include \"self.kdl\"")},
+		"invalid.kdl": {Data: []byte("node\nnode {")},
+		"nested.kdl":  {Data: []byte("nested {\n    include \"invalid.kdl\"\n}")},
+	}
+
+	tests := []struct {
+		testname string
+		body     string
+		pos      Position
+		message  string
+	}{
+		{
+			"cycle",
+			"root\ninclude \"a.kdl\"",
+			Position{Filename: "sub/b.kdl", Offset: 4, Line: 2, Column: 3},
+			"include cycle: a.kdl -> sub/b.kdl -> a.kdl",
+		},
+		{
+			"includes itself",
+			`include "self.kdl"`,
+			Position{Filename: "self.kdl", Offset: 0, Line: 1, Column: 1},
+			"include cycle: self.kdl -> self.kdl",
+		},
+		{
+			"invalid file",
+			"include \"nested.kdl\"",
+			Position{Filename: "invalid.kdl", Offset: 11, Line: 2, Column: 7},
+			"unexpected end of document: missing }",
+		},
+		{
+			"missing file",
+			"node\n(t)include \"missing.kdl\"",
+			Position{Offset: 5, Line: 2, Column: 1},
+			"open missing.kdl: file does not exist",
+		},
+		{
+			"outside root",
+			"include \"../a.kdl\"",
+			Position{Offset: 0, Line: 1, Column: 1},
+			`invalid path of included file: "../a.kdl"`,
+		},
+		{
+			"no arguments",
+			"include",
+			Position{Offset: 0, Line: 1, Column: 1},
+			"include must have a single argument: the path of a file",
+		},
+		{
+			"not a string",
+			"include 1",
+			Position{Offset: 0, Line: 1, Column: 1},
+			"include must have a single argument: the path of a file",
+		},
+		{
+			"properties",
+			`include "a.kdl" optional=true`,
+			Position{Offset: 0, Line: 1, Column: 1},
+			"include must have a single argument: the path of a file",
+		},
+		{
+			"children",
+			`include "a.kdl" { b; }`,
+			Position{Offset: 0, Line: 1, Column: 1},
+			"include must have a single argument: the path of a file",
+		},
+		{
+			"before syntax error",
+			"include\nnode {",
+			Position{Offset: 0, Line: 1, Column: 1},
+			"include must have a single argument: the path of a file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			_, err := ParseString(test.body, IncludeFiles(fsys))
+
+			// Assert
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, test.pos, perr.Pos)
+			require.EqualError(t, perr.Err, test.message)
+		})
+	}
+}
+
+func TestIncludeErrorMessage(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"a.kdl": {Data: []byte("a {")},
+	}
+
+	// Act
+	_, err := ParseString(`include "a.kdl"`, IncludeFiles(fsys))
+	_, missingErr := ParseString(`include "b.kdl"`, IncludeFiles(fsys))
+
+	// Assert
+	require.EqualError(t, err, "a.kdl:1:4: unexpected end of document: missing }")
+	require.ErrorIs(t, missingErr, fs.ErrNotExist)
+}
@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lunjon/gokdl/internal/benchdata"
+)
+
+func BenchmarkScanner(b *testing.B) {
+	benchmarkScanner(b, func(doc string) *Scanner {
+		return NewScanner(strings.NewReader(doc))
+	})
+}
+
+func BenchmarkScannerString(b *testing.B) {
+	benchmarkScanner(b, func(doc string) *Scanner {
+		return NewScannerString(context.Background(), doc)
+	})
+}
+
+func benchmarkScanner(b *testing.B, newScanner func(string) *Scanner) {
+	for _, shape := range benchdata.Shapes {
+		for _, size := range benchdata.Sizes {
+			doc := benchdata.Generate(shape, size.Bytes)
+			b.Run(fmt.Sprintf("%s-%s", shape, size.Name), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(doc)))
+				for i := 0; i < b.N; i++ {
+					sc := newScanner(doc)
+					for tok, _ := sc.Scan(); tok != EOF; tok, _ = sc.Scan() {
+					}
+				}
+			})
+		}
+	}
+}
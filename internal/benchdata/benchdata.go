@@ -0,0 +1,167 @@
+// Package benchdata generates KDL documents used in benchmarks.
+//
+// The documents are generated deterministically so that
+// benchmark results can be compared between revisions,
+// e.g. using benchstat.
+package benchdata
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Shape describes the contents of a generated document.
+type Shape string
+
+const (
+	// Mixed is a realistic configuration-like document.
+	Mixed Shape = "mixed"
+	// Deep contains deeply nested children.
+	Deep Shape = "deep"
+	// Wide contains nodes with many properties.
+	Wide Shape = "wide"
+	// Strings contains long string arguments.
+	Strings Shape = "strings"
+	// Numbers contains many numeric arguments.
+	Numbers Shape = "numbers"
+)
+
+// Shapes lists all shapes.
+var Shapes = []Shape{Mixed, Deep, Wide, Strings, Numbers}
+
+// Size is the approximate size of a generated document.
+type Size struct {
+	Name  string
+	Bytes int
+}
+
+// Sizes lists the sizes used in benchmarks.
+var Sizes = []Size{
+	{"small", 1 << 10},
+	{"medium", 100 << 10},
+	{"large", 10 << 20},
+}
+
+// Generate returns a document of the shape with about size bytes.
+// The same arguments always generate the same document.
+func Generate(shape Shape, size int) string {
+	g := &generator{
+		rnd: rand.New(rand.NewSource(int64(len(shape)*size + 1))),
+	}
+
+	var write func(int)
+	switch shape {
+	case Mixed:
+		write = g.mixed
+	case Deep:
+		write = g.deep
+	case Wide:
+		write = g.wide
+	case Strings:
+		write = g.strings
+	case Numbers:
+		write = g.numbers
+	default:
+		panic(fmt.Sprintf("unknown shape: %s", shape))
+	}
+
+	for i := 0; g.b.Len() < size; i++ {
+		write(i)
+	}
+	return g.b.String()
+}
+
+type generator struct {
+	b   strings.Builder
+	rnd *rand.Rand
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.b, format, args...)
+}
+
+func (g *generator) indent(depth int) {
+	g.b.WriteString(strings.Repeat("    ", depth))
+}
+
+func (g *generator) mixed(i int) {
+	g.printf("// Service number %d\n", i)
+	g.printf("service \"service-%d\" enabled=true replicas=%d {\n", i, g.rnd.Intn(10))
+	g.printf("    image \"registry.example.com/service-%d:1.%d.%d\"\n", i, g.rnd.Intn(20), g.rnd.Intn(100))
+	g.printf("    port (u16)%d protocol=\"tcp\"\n", 1024+g.rnd.Intn(60000))
+	g.printf("    limits cpu=%.2f memory=(u64)%d\n", g.rnd.Float64()*4, g.rnd.Intn(1<<32))
+	g.printf("    /-disabled-feature true\n")
+	g.printf("    env {\n")
+	for j := 0; j < 3; j++ {
+		g.printf("        VAR_%d \"value %d\"; other-%d null\n", j, g.rnd.Int(), j)
+	}
+	g.printf("    }\n")
+	g.printf("    command r#\"/bin/sh -c \"exec service --id %d\"\"#\n", i)
+	g.printf("}\n\n")
+}
+
+func (g *generator) deep(i int) {
+	depth := 20 + g.rnd.Intn(30)
+	for d := 0; d < depth; d++ {
+		g.indent(d)
+		g.printf("level-%d depth=%d {\n", d, d)
+	}
+	g.indent(depth)
+	g.printf("leaf %d\n", i)
+	for d := depth - 1; d >= 0; d-- {
+		g.indent(d)
+		g.printf("}\n")
+	}
+}
+
+func (g *generator) wide(i int) {
+	g.printf("node-%d", i)
+	for j := 0; j < 50; j++ {
+		switch j % 4 {
+		case 0:
+			g.printf(" prop-%d=%d", j, g.rnd.Int63())
+		case 1:
+			g.printf(" prop-%d=\"value-%d\"", j, g.rnd.Intn(1000))
+		case 2:
+			g.printf(" prop-%d=%t", j, g.rnd.Intn(2) == 0)
+		case 3:
+			g.printf(" (annotated)prop-%d=(f64)%.3f", j, g.rnd.Float64())
+		}
+	}
+	g.printf("\n")
+}
+
+const words = "lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor"
+
+func (g *generator) strings(i int) {
+	fields := strings.Fields(words)
+	var sentence strings.Builder
+	for j := 0; j < 200; j++ {
+		sentence.WriteString(fields[g.rnd.Intn(len(fields))])
+		sentence.WriteString(" ")
+	}
+
+	g.printf("text-%d \"%s\"\n", i, sentence.String())
+	g.printf("escaped-%d \"line one\\nline \\\"two\\\"\\t%s\"\n", i, sentence.String())
+	g.printf("raw-%d r##\"%s \"quoted\" \\not escaped\"##\n", i, sentence.String())
+}
+
+func (g *generator) numbers(i int) {
+	g.printf("numbers-%d", i)
+	for j := 0; j < 20; j++ {
+		switch j % 5 {
+		case 0:
+			g.printf(" %d", g.rnd.Int63()-g.rnd.Int63())
+		case 1:
+			g.printf(" %f", g.rnd.NormFloat64()*1000)
+		case 2:
+			g.printf(" %.3e", g.rnd.ExpFloat64())
+		case 3:
+			g.printf(" 0x%x", g.rnd.Int31())
+		case 4:
+			g.printf(" (u8)%d", g.rnd.Intn(256))
+		}
+	}
+	g.printf("\n")
+}
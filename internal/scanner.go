@@ -2,61 +2,154 @@ package internal
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
+// How many runes are read between each check of the context.
+const ctxCheckInterval = 1024
+
 type previous struct {
 	token Token
 	lit   string
+	start Position
+}
+
+// Position of a rune in the source.
+type Position struct {
+	Offset int // Byte offset, starting at 0
+	Line   int // Line number, starting at 1
+	Column int // Column number in runes, starting at 1
 }
 
 // Scanner represents a lexical Scanner.
 type Scanner struct {
-	r   *bufio.Reader
+	r *bufio.Reader
+	// The source when scanning a string, which is indexed
+	// directly and literals are sliced from instead of copied.
+	src        string
+	fromString bool
+	canUnread  bool
+
 	eof bool
+	err error
+	ctx context.Context
+	// Number of runes read, used to check the context periodically.
+	reads int
+	// Position of the next rune and the position before the last
+	// read rune, which is restored when unreading.
+	pos     Position
+	lastPos Position
+	lastCR  bool
+	prevCR  bool
+	// Position of the start of the last scanned token.
+	tokenPos Position
 	// State used in unread.
-	prev *previous // Set from last when Unread was called
-	last previous
+	prev    previous // Set from last when Unread was called
+	hasPrev bool
+	last    previous
 }
 
 func NewScanner(r io.Reader) *Scanner {
+	return NewScannerContext(context.Background(), r)
+}
+
+// NewScannerContext returns a scanner that stops
+// scanning once ctx is done. The error of the context
+// is then returned by Err.
+func NewScannerContext(ctx context.Context, r io.Reader) *Scanner {
 	return &Scanner{
-		r: bufio.NewReader(r),
+		r:   bufio.NewReader(r),
+		ctx: ctx,
+		pos: Position{Line: 1, Column: 1},
 	}
 }
 
-func (s *Scanner) ScanLine() {
-	if s.eof {
-		return
+// NewScannerString returns a scanner that reads from src.
+// It is faster than reading src using an io.Reader since
+// literals are sliced from src instead of being copied.
+func NewScannerString(ctx context.Context, src string) *Scanner {
+	return &Scanner{
+		src:        src,
+		fromString: true,
+		ctx:        ctx,
+		pos:        Position{Line: 1, Column: 1},
+	}
+}
+
+// Pos returns the position of the next rune to be read.
+func (s *Scanner) Pos() Position {
+	return s.pos
+}
+
+// TokenPos returns the position of the start
+// of the token last returned by Scan.
+func (s *Scanner) TokenPos() Position {
+	return s.tokenPos
+}
+
+// CanSlice reports whether the scanner reads from
+// a string that literals can be sliced from using Slice.
+func (s *Scanner) CanSlice() bool {
+	return s.fromString
+}
+
+// Slice returns the source between the byte offsets start and end.
+// It must only be called if CanSlice returns true.
+func (s *Scanner) Slice(start, end int) string {
+	return s.src[start:end]
+}
+
+// Err returns the first error, other than io.EOF,
+// that was encountered while reading.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// ScanLine reads the rest of the line, including the
+// newline, and returns it excluding the newline.
+func (s *Scanner) ScanLine() string {
+	line := s.ScanWhile(func(r rune) bool {
+		return !IsNewline(r)
+	})
+
+	if s.read() == '\r' {
+		// CRLF is a single newline
+		if s.read() != '\n' {
+			s.unread()
+		}
 	}
-	_, _ = s.r.ReadBytes('\n')
+	return line
 }
 
 // scan returns the next token and literal value.
 func (s *Scanner) Scan() (tok Token, lit string) {
-	if s.eof {
-		return EOF, ""
+	if s.hasPrev {
+		s.hasPrev = false
+		s.tokenPos = s.prev.start
+		return s.prev.token, s.prev.lit
 	}
 
-	if s.prev != nil {
-		token := s.prev.token
-		lit := s.prev.lit
-		s.prev = nil
-		return token, lit
+	if s.eof {
+		s.tokenPos = s.pos
+		return s.setAndReturn(EOF, "")
 	}
 
+	s.tokenPos = s.pos
+
 	ch := s.read()
 
 	if unicode.IsSpace(ch) {
-		s.r.UnreadRune()
+		s.unread()
 		return s.ScanWhitespace()
-	} else if unicode.IsDigit(ch) {
-		s.r.UnreadRune()
+	} else if IsDigit(ch) {
+		s.unread()
 		return s.scanNumber(false)
 	}
 
@@ -73,10 +166,10 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 		str = string(ch)
 	case '-':
 		next := s.read()
-		s.r.UnreadRune()
+		s.unread()
 
-		if unicode.IsDigit(next) {
-			s.r.UnreadRune()
+		if IsDigit(next) {
+			s.unread()
 			return s.scanNumber(true)
 		}
 
@@ -84,10 +177,10 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 		str = string(ch)
 	case '+':
 		next := s.read()
-		s.r.UnreadRune()
+		s.unread()
 
-		if unicode.IsDigit(next) {
-			s.r.UnreadRune()
+		if IsDigit(next) {
+			s.unread()
 			return s.scanNumber(false)
 		}
 
@@ -99,7 +192,7 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 			token = COMMENT_MUL_CLOSE
 			str = "*/"
 		} else {
-			s.r.UnreadRune()
+			s.unread()
 			token = CHAR
 			str = string(ch)
 		}
@@ -116,8 +209,8 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 			token = COMMENT_SD
 			str = "/-"
 		default:
-			s.r.UnreadRune()
-			return CHAR, string(ch)
+			s.unread()
+			return s.setAndReturn(CHAR, string(ch))
 		}
 	case ';':
 		token = SEMICOLON
@@ -154,6 +247,8 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 		str = string(ch)
 	case 'r':
 		return s.scanRawString()
+	case '#':
+		return s.scanHash()
 	default:
 		token = CHAR
 		str = string(ch)
@@ -166,7 +261,7 @@ func (s *Scanner) scanRawString() (Token, string) {
 	next := s.read()
 	switch next {
 	case '"':
-		return RAWSTR_OPEN, `r"`
+		return s.setAndReturn(RAWSTR_OPEN, `r"`)
 	case '#':
 		lit := s.ScanWhile(func(r rune) bool {
 			return r == '#'
@@ -174,44 +269,89 @@ func (s *Scanner) scanRawString() (Token, string) {
 
 		next := s.read()
 		if next != '"' {
-			s.r.UnreadRune()
-			return CHAR, fmt.Sprintf("r#%s", lit)
+			s.unread()
+			return s.setAndReturn(CHAR, fmt.Sprintf("r#%s", lit))
 		}
 
-		return RAWSTR_HASH_OPEN, fmt.Sprintf(`r#%s"`, lit)
+		return s.setAndReturn(RAWSTR_HASH_OPEN, fmt.Sprintf(`r#%s"`, lit))
 	default:
-		s.r.UnreadRune()
-		return CHAR, "r"
+		s.unread()
+		return s.setAndReturn(CHAR, "r")
+	}
+}
+
+// scanHash scans hashes that start a raw string, e.g. #"raw"#,
+// or an identifier, e.g. #name.
+func (s *Scanner) scanHash() (Token, string) {
+	lit := "#" + s.ScanWhile(func(r rune) bool {
+		return r == '#'
+	})
+
+	if s.read() != '"' {
+		s.unread()
+		return s.setAndReturn(CHAR, lit)
 	}
+	return s.setAndReturn(RAWSTR_HASH_OPEN, lit+`"`)
 }
 
 // Handles a single " as well as "##...
 func (s *Scanner) scanQuote() (Token, string) {
 	next := s.read()
 	if next != '#' {
-		s.r.UnreadRune()
-		return QUOTE, `"`
+		s.unread()
+		return s.setAndReturn(QUOTE, `"`)
 	}
 
 	lit := s.ScanWhile(func(r rune) bool {
 		return r == '#'
 	})
-	return RAWSTR_HASH_CLOSE, `"#` + lit
+	return s.setAndReturn(RAWSTR_HASH_CLOSE, `"#`+lit)
+}
+
+// ScanPrefix reports whether the input continues with prefix,
+// in which case it is consumed. Otherwise nothing is consumed.
+func (s *Scanner) ScanPrefix(prefix string) bool {
+	if s.eof {
+		return false
+	}
+
+	if s.fromString {
+		if !strings.HasPrefix(s.src[s.pos.Offset:], prefix) {
+			return false
+		}
+	} else if bs, err := s.r.Peek(len(prefix)); err != nil || string(bs) != prefix {
+		return false
+	}
+
+	for range prefix {
+		s.read()
+	}
+	return true
 }
 
 func (s *Scanner) ScanWhile(pred func(rune) bool) string {
-	var buf bytes.Buffer
-	if s.prev != nil {
-		buf.WriteString(s.prev.lit)
-		s.prev = nil
+	var prefix string
+	if s.hasPrev {
+		prefix = s.prev.lit
+		s.hasPrev = false
 	}
 
+	if s.fromString {
+		start, end := s.skipWhile(pred)
+		if prefix == "" {
+			return s.src[start:end]
+		}
+		return prefix + s.src[start:end]
+	}
+
+	var buf strings.Builder
+	buf.WriteString(prefix)
 	for {
 		ch := s.read()
 		if ch == EOF_RUNE {
 			break
 		} else if !pred(ch) {
-			s.r.UnreadRune()
+			s.unread()
 			break
 		} else {
 			buf.WriteRune(ch)
@@ -221,10 +361,26 @@ func (s *Scanner) ScanWhile(pred func(rune) bool) string {
 	return buf.String()
 }
 
+// skipWhile reads runes while pred is true and
+// returns the start and end offsets of the runes read.
+func (s *Scanner) skipWhile(pred func(rune) bool) (int, int) {
+	start := s.pos.Offset
+	for {
+		end := s.pos.Offset
+		ch := s.read()
+		if ch == EOF_RUNE {
+			return start, end
+		} else if !pred(ch) {
+			s.unread()
+			return start, end
+		}
+	}
+}
+
 // scanNumber tries to scan a number in any of the supported formats.
 // Use `neg` to indicate that the number was prefixed with a hyphen.
 func (s *Scanner) scanNumber(neg bool) (Token, string) {
-	start := s.ScanWhile(unicode.IsDigit)
+	start := s.ScanWhile(IsDigit)
 	next := s.read()
 	if neg {
 		start = "-" + start
@@ -235,74 +391,62 @@ func (s *Scanner) scanNumber(neg bool) (Token, string) {
 	}
 
 	comp := start + string(next)
-
-	if strings.HasSuffix(comp, ".") {
-		return s.scanFloat(comp)
-	} else if comp == "0x" {
+	if comp == "0x" {
 		return s.scanHex()
 	} else if comp == "0o" {
 		return s.scanOctal()
 	} else if comp == "0b" {
 		return s.scanBinary()
 	}
+	s.unread()
 
-	if next != '_' {
-		if unicode.IsSpace(next) || !unicode.IsDigit(next) {
-			s.r.UnreadRune()
-			return s.setAndReturn(NUM_INT, start)
-		}
-	}
+	return s.scanDecimal(start)
+}
 
-	// Read as integer
-	s.r.UnreadRune()
-	lit := s.ScanWhile(func(r rune) bool {
-		return unicode.IsDigit(r) || r == '_'
-	})
+// scanDecimal scans the rest of a decimal number, given the
+// leading digits, with an optional fraction and exponent:
+// 1_000, 1.234 or 1.234e-42.
+func (s *Scanner) scanDecimal(start string) (Token, string) {
+	isDigit := func(r rune) bool {
+		return IsDigit(r) || r == '_'
+	}
 
-	return s.setAndReturn(NUM_INT, strings.ReplaceAll(start+lit, "_", ""))
-}
+	token := NUM_INT
+	lit := start + s.ScanWhile(isDigit)
 
-func (s *Scanner) scanFloat(start string) (Token, string) {
-	// Try scientific notation: 1.234e-42
-	if len(strings.TrimPrefix(start, "-")) == 2 {
-		numsAfterDot := s.ScanWhile(unicode.IsDigit)
-		if numsAfterDot == "" {
-			return s.setAndReturn(CHARS, start)
+	next := s.read()
+	if next == '.' {
+		fraction := s.ScanWhile(isDigit)
+		if fraction == "" {
+			return s.setAndReturn(CHARS, lit+".")
 		}
 
-		tokenAfterNums, sAfterNums := s.Scan()
-
-		if tokenAfterNums == CHAR && sAfterNums == "e" {
-			next, ch := s.Scan()
-			var exp string
-
-			if ch == "-" {
-				exp = s.ScanWhile(unicode.IsDigit)
-				exp = "-" + exp
-			} else if next == NUM_INT {
-				exp = ch
-			} else {
-				return CHARS, start + numsAfterDot + sAfterNums + ch
-			}
-
-			num := fmt.Sprintf("%s%se%s", start, numsAfterDot, exp)
-			return s.setAndReturn(NUM_SCI, num)
-		} else if tokenAfterNums == NUM_INT {
-			num := start + numsAfterDot + sAfterNums
-			return s.setAndReturn(NUM_FLOAT, num)
-		} else if tokenAfterNums == WS || tokenAfterNums == EOF {
-			s.Unread()
-			return s.setAndReturn(NUM_FLOAT, start+numsAfterDot)
+		token = NUM_FLOAT
+		lit += "." + fraction
+		next = s.read()
+	}
+
+	if next == 'e' || next == 'E' {
+		exp := string(next)
+		sign := s.read()
+		if sign == '+' || sign == '-' {
+			exp += string(sign)
+		} else if sign != EOF_RUNE {
+			s.unread()
 		}
 
-	}
+		digits := s.ScanWhile(isDigit)
+		if digits == "" {
+			return s.setAndReturn(CHARS, lit+exp)
+		}
 
-	numsAfterDot := s.ScanWhile(unicode.IsDigit)
-	if numsAfterDot == "" {
-		return s.setAndReturn(CHARS, start)
+		token = NUM_SCI
+		lit += exp + digits
+	} else if next != EOF_RUNE {
+		s.unread()
 	}
 
-	return s.setAndReturn(NUM_FLOAT, start+numsAfterDot)
+	return s.setAndReturn(token, strings.ReplaceAll(lit, "_", ""))
 }
 
 func (s *Scanner) scanBinary() (Token, string) {
@@ -350,6 +494,21 @@ func (s *Scanner) scanHex() (Token, string) {
 	return s.setAndReturn(NUM_INT, fmt.Sprint(n))
 }
 
+// ScanRune returns the next rune as is, i.e. without
+// tokenizing it. This is used to read the contents of strings.
+// Returns EOF_RUNE when there is nothing more to read.
+func (s *Scanner) ScanRune() rune {
+	if s.eof {
+		return EOF_RUNE
+	}
+	return s.read()
+}
+
+// UnscanRune unreads the last rune returned by ScanRune.
+func (s *Scanner) UnscanRune() {
+	s.unread()
+}
+
 // Scan while whitespace only.
 func (s *Scanner) ScanWhitespace() (Token, string) {
 	lit := s.ScanWhile(unicode.IsSpace)
@@ -374,20 +533,128 @@ func (s *Scanner) ScanBareIdent() string {
 
 // Read the next rune from the reader.
 // Returns `eof` if an error occurs (or io.EOF is returned).
+// Errors other than io.EOF are available from Err.
 func (s *Scanner) read() rune {
-	r, _, err := s.r.ReadRune()
+	s.reads++
+	if s.reads%ctxCheckInterval == 0 {
+		if err := s.ctx.Err(); err != nil {
+			s.fail(err)
+			return EOF_RUNE
+		}
+	}
+
+	var r rune
+	var size int
+	var err error
+	if s.fromString {
+		r, size, err = s.readString()
+	} else {
+		r, size, err = s.r.ReadRune()
+	}
+
 	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.fail(err)
+		}
 		s.eof = true
 		return EOF_RUNE
 	}
+
+	if r == BOM && s.pos.Offset == 0 {
+		// A leading byte order mark is ignored
+		s.pos.Offset += size
+		return s.read()
+	}
+
+	if err := checkRune(r, size); err != nil {
+		// Stop at the invalid rune so that the position
+		// of the scanner is the position of the rune
+		if s.fromString {
+			s.canUnread = false
+		} else {
+			_ = s.r.UnreadRune()
+		}
+		s.fail(err)
+		return EOF_RUNE
+	}
+
+	s.lastPos = s.pos
+	s.lastCR = s.prevCR
+	s.pos.Offset += size
+	switch r {
+	case '\n':
+		// CRLF counts as a single newline
+		if !s.prevCR {
+			s.pos.Line++
+		}
+		s.pos.Column = 1
+	case '\r', '\f', '\u0085', '\u2028', '\u2029':
+		s.pos.Line++
+		s.pos.Column = 1
+	default:
+		s.pos.Column++
+	}
+	s.prevCR = r == '\r'
 	return r
 }
 
+// checkRune returns an error if the rune, of the given size
+// in bytes, is invalid UTF-8 or a disallowed code point.
+func checkRune(r rune, size int) error {
+	if r == utf8.RuneError && size == 1 {
+		return fmt.Errorf("invalid UTF-8 encoding")
+	} else if IsDisallowed(r) {
+		return fmt.Errorf("disallowed code point U+%04X", r)
+	}
+	return nil
+}
+
+// readString reads the next rune from the source string.
+func (s *Scanner) readString() (rune, int, error) {
+	offset := s.pos.Offset
+	if offset >= len(s.src) {
+		s.canUnread = false
+		return 0, 0, io.EOF
+	}
+
+	s.canUnread = true
+	if c := s.src[offset]; c < utf8.RuneSelf {
+		return rune(c), 1, nil
+	}
+
+	r, size := utf8.DecodeRuneInString(s.src[offset:])
+	return r, size, nil
+}
+
+// Unread the last rune read and restore the position.
+// Just as for bufio.Reader only one rune can be unread.
+func (s *Scanner) unread() {
+	if s.fromString {
+		if !s.canUnread {
+			return
+		}
+		s.canUnread = false
+	} else if s.r.UnreadRune() != nil {
+		return
+	}
+
+	s.pos = s.lastPos
+	s.prevCR = s.lastCR
+}
+
+func (s *Scanner) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+	s.eof = true
+}
+
 func (s *Scanner) setAndReturn(t Token, lit string) (Token, string) {
-	s.last = previous{token: t, lit: lit}
+	s.last = previous{token: t, lit: lit, start: s.tokenPos}
 	return t, lit
 }
 
 func (s *Scanner) Unread() {
-	s.prev = &s.last
+	s.prev = s.last
+	s.hasPrev = true
 }
@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -43,6 +46,11 @@ func TestScannerScanNumbers(t *testing.T) {
 		{"float - scientific (pos exp)", "1.123e12", NUM_SCI, "1.123e12"},
 		{"float - scientific (neg exp)", "1.123e-9", NUM_SCI, "1.123e-9"},
 		{"float - scientific neg", "-1.123e9", NUM_SCI, "-1.123e9"},
+		{"float - scientific without fraction", "1e9", NUM_SCI, "1e9"},
+		{"float - scientific plus sign", "1.5E+9", NUM_SCI, "1.5E+9"},
+		{"float - underscore", "1_000.000_1", NUM_FLOAT, "1000.0001"},
+		{"float - missing fraction", "1.", CHARS, "1."},
+		{"float - missing exponent", "1.5e", CHARS, "1.5e"},
 		{"binary", "0b0101", NUM_INT, "5"},
 		{"binary - underscore", "0b01_01", NUM_INT, "5"},
 		{"octal", "0o010463", NUM_INT, "4403"},
@@ -61,6 +69,23 @@ func TestScannerScanNumbers(t *testing.T) {
 	}
 }
 
+func TestScannerUnreadAtEOF(t *testing.T) {
+	sc := setup("12")
+	token, _ := sc.Scan()
+	require.Equal(t, NUM_INT, token)
+
+	sc.Unread()
+	token, lit := sc.Scan()
+	require.Equal(t, NUM_INT, token)
+	require.Equal(t, "12", lit)
+
+	token, _ = sc.Scan()
+	require.Equal(t, EOF, token)
+	sc.Unread()
+	token, _ = sc.Scan()
+	require.Equal(t, EOF, token)
+}
+
 func TestScannerScanRawString(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -88,6 +113,73 @@ func TestScannerScanRawString(t *testing.T) {
 	}
 }
 
+func TestScannerPos(t *testing.T) {
+	tests := []struct {
+		name     string
+		str      string
+		expected Position
+	}{
+		{"start", "", Position{Offset: 0, Line: 1, Column: 1}},
+		{"same line", "node", Position{Offset: 4, Line: 1, Column: 5}},
+		{"newline", "a\nb", Position{Offset: 3, Line: 2, Column: 2}},
+		{"carriage return newline", "a\r\nb", Position{Offset: 4, Line: 2, Column: 2}},
+		{"multiple newlines", "a\n\r\u2028b", Position{Offset: 7, Line: 4, Column: 2}},
+		{"multibyte runes", "åäö", Position{Offset: 6, Line: 1, Column: 4}},
+	}
+
+	for _, test := range tests {
+		sc := setup(test.str)
+		t.Run(test.name, func(t *testing.T) {
+			for tok, _ := sc.Scan(); tok != EOF; tok, _ = sc.Scan() {
+			}
+			require.Equal(t, test.expected, sc.Pos())
+		})
+	}
+}
+
+func TestScannerContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sc := NewScannerContext(ctx, strings.NewReader(strings.Repeat("a ", ctxCheckInterval)))
+	for tok, _ := sc.Scan(); tok != EOF; tok, _ = sc.Scan() {
+	}
+	require.ErrorIs(t, sc.Err(), context.Canceled)
+}
+
+func FuzzScanner(f *testing.F) {
+	filenames, err := filepath.Glob("../testdata/*.kdl")
+	require.NoError(f, err)
+	for _, filename := range filenames {
+		bs, err := os.ReadFile(filename)
+		require.NoError(f, err)
+		f.Add(string(bs))
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		sc := setup(src)
+
+		// Every token consumes at least one rune
+		// so scanning must terminate within len(src) tokens.
+		for i := 0; ; i++ {
+			if i > len(src) {
+				t.Fatalf("scanner did not reach EOF after %d tokens", i)
+			}
+
+			prev := sc.Pos()
+			token, _ := sc.Scan()
+			pos := sc.Pos()
+			if pos.Offset < prev.Offset || pos.Offset > len(src) {
+				t.Fatalf("invalid position after token %d: %v -> %v", token, prev, pos)
+			}
+
+			if token == EOF {
+				break
+			}
+		}
+	})
+}
+
 func setup(source string) *Scanner {
 	r := strings.NewReader(source)
 	return NewScanner(r)
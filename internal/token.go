@@ -4,6 +4,8 @@ import (
 	"unicode"
 )
 
+// EOF_RUNE is returned by the scanner at the end of the input.
+// NUL is a disallowed code point, so it is never returned otherwise.
 var EOF_RUNE = rune(0)
 
 type Token int
@@ -41,7 +43,7 @@ const (
 	SBRACK_CLOSE      // ]
 	COMMA             // ,
 	RAWSTR_OPEN       // r"
-	RAWSTR_HASH_OPEN  // r#[...]"
+	RAWSTR_HASH_OPEN  // r#[...]" or #[...]"
 	RAWSTR_HASH_CLOSE // "#[...]
 
 	// Other characters
@@ -62,8 +64,43 @@ func IsIdentifierToken(t Token) bool {
 	}
 }
 
+// IsIdentifier reports whether r can be part of a bare identifier.
 func IsIdentifier(r rune) bool {
-	return !nonIdents[r] && !unicode.IsSpace(r)
+	return !nonIdents[r] && !unicode.IsSpace(r) && !IsDisallowed(r)
+}
+
+// The byte order mark, which is only allowed
+// at the start of a document and then ignored.
+const BOM = '\uFEFF'
+
+// IsDisallowed reports whether r is a code point that is not
+// allowed anywhere in a document, not even in strings or comments.
+// These can only be written in strings using unicode escapes.
+func IsDisallowed(r rune) bool {
+	switch {
+	case r <= 0x08, 0x0E <= r && r <= 0x1F, r == 0x7F:
+		// Control characters, except whitespace and newlines
+		return true
+	case 0xD800 <= r && r <= 0xDFFF:
+		// Surrogates
+		return true
+	case r == 0x200E, r == 0x200F, 0x202A <= r && r <= 0x202E, 0x2066 <= r && r <= 0x2069:
+		// Direction control characters
+		return true
+	case r == BOM:
+		return true
+	}
+	return false
+}
+
+// IsNewline reports whether r is a newline character.
+func IsNewline(r rune) bool {
+	switch r {
+	case '\n', '\r', '\f', '\u0085', '\u2028', '\u2029':
+		return true
+	default:
+		return false
+	}
 }
 
 func IsAnyOf(t Token, ts ...Token) bool {
@@ -75,18 +112,61 @@ func IsAnyOf(t Token, ts ...Token) bool {
 	return false
 }
 
-func ContainsNonIdent(s string) bool {
-	for _, ch := range s {
-		if nonIdents[ch] {
-			return true
+// Keywords that look like identifiers but are not allowed as such.
+var reservedKeywords = map[string]bool{
+	"true":  true,
+	"false": true,
+	"null":  true,
+	"inf":   true,
+	"-inf":  true,
+	"nan":   true,
+}
+
+// IsKeyword reports whether s is a keyword, which
+// is not allowed as a bare identifier.
+func IsKeyword(s string) bool {
+	return reservedKeywords[s]
+}
+
+// IsDigit reports whether r is a decimal digit.
+// Only ASCII digits are digits in KDL.
+func IsDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+// IsBareIdent reports whether s is a valid bare identifier,
+// i.e. one that can be written without quotes:
+//
+//   - It consists only of identifier runes, see IsIdentifier.
+//   - It does not start with a digit, or a sign followed by
+//     a digit, since it then would be a number.
+//   - It does not start with a dot followed by a digit, optionally
+//     after a sign, since it then would look like a number.
+//   - It is not a keyword, e.g. true or inf.
+func IsBareIdent(s string) bool {
+	if s == "" || IsKeyword(s) {
+		return false
+	}
+
+	for _, r := range s {
+		if !IsIdentifier(r) {
+			return false
 		}
 	}
-	return false
+
+	runes := []rune(s)
+	if runes[0] == '-' || runes[0] == '+' {
+		runes = runes[1:]
+	}
+	if len(runes) > 0 && runes[0] == '.' {
+		runes = runes[1:]
+	}
+	return len(runes) == 0 || !IsDigit(runes[0])
 }
 
 func init() {
 	nonIdents = map[rune]bool{}
-	for _, r := range `\/(){}<>;[]=,` {
+	for _, r := range `\/(){}<>;[]=,"#` {
 		nonIdents[r] = true
 	}
 
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBareIdent(t *testing.T) {
+	tests := []struct {
+		ident    string
+		expected bool
+	}{
+		{"node", true},
+		{"node_name123", true},
+		{"-", true},
+		{"+", true},
+		{"-abc", true},
+		{"--x", true},
+		{"+-1", true},
+		{".", true},
+		{".x", true},
+		{"-.", true},
+		{"+.x", true},
+		{"..5", true},
+		{"a.5", true},
+		{"trueish", true},
+		{"true1", true},
+		{"null-x", true},
+		{"infinity", true},
+		{"٣x", true}, // Not an ASCII digit
+		{"", false},
+		{"1abc", false},
+		{"-1abc", false},
+		{"+1", false},
+		{".5", false},
+		{"-.5x", false},
+		{"+.0", false},
+		{"true", false},
+		{"false", false},
+		{"null", false},
+		{"inf", false},
+		{"-inf", false},
+		{"nan", false},
+		{"#name", false},
+		{"a#b", false},
+		{"a b", false},
+		{`a"b`, false},
+		{"a=b", false},
+		{"a\u202eb", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ident, func(t *testing.T) {
+			require.Equal(t, test.expected, IsBareIdent(test.ident))
+		})
+	}
+}
@@ -1,8 +1,18 @@
 package gokdl_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/lunjon/gokdl"
 )
@@ -47,3 +57,212 @@ hello \
 		t.Fatalf("expected no error but was: %s", err)
 	}
 }
+
+func TestParseContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := gokdl.ParseContext(ctx, strings.NewReader("node 1 2 3"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled but was: %v", err)
+	}
+
+	var perr *gokdl.ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *ParseError but was: %T", err)
+	}
+}
+
+func TestParseContextCanceledWhileReading(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel the context once half of the document has been read
+	doc := strings.Repeat("node 1 2 3 prop=\"value\"\n", 10_000)
+	r := &cancelingReader{
+		r:      strings.NewReader(doc),
+		cancel: cancel,
+		after:  len(doc) / 2,
+	}
+
+	_, err := gokdl.ParseContext(ctx, r)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled but was: %v", err)
+	}
+
+	var perr *gokdl.ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *ParseError but was: %T", err)
+	}
+	if perr.Pos.Line <= 1 || perr.Pos.Offset >= len(doc) {
+		t.Fatalf("expected position within the document but was: %s", perr.Pos)
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := gokdl.Parse(strings.NewReader("node\nother (u8)\"value\""))
+
+	var perr *gokdl.ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *ParseError but was: %v", err)
+	}
+	if perr.Pos.Line != 2 {
+		t.Fatalf("expected error on line 2 but was: %s", perr.Pos)
+	}
+}
+
+// cancelingReader calls cancel once `after` bytes have been read.
+type cancelingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+	after  int
+	read   int
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += n
+	if r.read >= r.after {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestParseStringAndBytes(t *testing.T) {
+	filenames, err := filepath.Glob("testdata/*.kdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filename := range filenames {
+		t.Run(filepath.Base(filename), func(t *testing.T) {
+			bs, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected, err := gokdl.Parse(bytes.NewReader(bs))
+			if err != nil {
+				t.Fatalf("expected no error but was: %s", err)
+			}
+
+			fromString, err := gokdl.ParseString(string(bs))
+			if err != nil {
+				t.Fatalf("expected no error but was: %s", err)
+			}
+
+			fromBytes, err := gokdl.ParseBytes(bs)
+			if err != nil {
+				t.Fatalf("expected no error but was: %s", err)
+			}
+
+			if !reflect.DeepEqual(expected, fromString) {
+				t.Fatalf("expected ParseString to equal Parse:\n%s\n%s", expected, fromString)
+			}
+			if !reflect.DeepEqual(expected, fromBytes) {
+				t.Fatalf("expected ParseBytes to equal Parse:\n%s\n%s", expected, fromBytes)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	doc, err := gokdl.ParseFile("testdata/example.kdl")
+	if err != nil {
+		t.Fatalf("expected no error but was: %s", err)
+	}
+	if doc.Filename() != "testdata/example.kdl" {
+		t.Fatalf("expected file name of the document but was: %q", doc.Filename())
+	}
+
+	expected, err := gokdl.ParseFS(os.DirFS("testdata"), "example.kdl")
+	if err != nil {
+		t.Fatalf("expected no error but was: %s", err)
+	}
+	if !reflect.DeepEqual(expected.Nodes(), doc.Nodes()) {
+		t.Fatalf("expected ParseFile to equal ParseFS:\n%s\n%s", expected, doc)
+	}
+}
+
+func TestParseFileError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.kdl")
+	if err := os.WriteFile(path, []byte("node\nnode {"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := gokdl.ParseFile(path)
+	var perr *gokdl.ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *ParseError but was: %v", err)
+	}
+	if perr.Pos.Filename != path {
+		t.Fatalf("expected the file name in the position but was: %s", perr.Pos)
+	}
+	if expected := path + ":2:7: unexpected end of document: missing }"; err.Error() != expected {
+		t.Fatalf("expected error %q but was %q", expected, err)
+	}
+
+	_, err = gokdl.ParseFile(filepath.Join(t.TempDir(), "missing.kdl"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a missing file error but was: %v", err)
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/app.kdl":     {Data: []byte("app {\n    include \"db.kdl\"\n}")},
+		"conf/db.kdl":      {Data: []byte("db \"localhost\"")},
+		"conf/invalid.kdl": {Data: []byte("invalid {")},
+	}
+
+	doc, err := gokdl.ParseFS(fsys, "conf/app.kdl", gokdl.IncludeFiles(fsys))
+	if err != nil {
+		t.Fatalf("expected no error but was: %s", err)
+	}
+	if expected := "app {\n    db \"localhost\"\n}\n"; doc.String() != expected {
+		t.Fatalf("expected the included file to be resolved relative to the document but was:\n%s", doc)
+	}
+	if doc.Filename() != "conf/app.kdl" {
+		t.Fatalf("expected file name of the document but was: %q", doc.Filename())
+	}
+
+	_, err = gokdl.ParseFS(fsys, "conf/invalid.kdl")
+	var perr *gokdl.ParseError
+	if !errors.As(err, &perr) || perr.Pos.Filename != "conf/invalid.kdl" {
+		t.Fatalf("expected a *ParseError with the file name but was: %v", err)
+	}
+}
+
+func TestParseGlob(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.kdl":         {Data: []byte("a")},
+		"b.kdl":         {Data: []byte("b 1")},
+		"c.txt":         {Data: []byte("not kdl {")},
+		"sub/d.kdl":     {Data: []byte("d")},
+		"invalid/e.kdl": {Data: []byte("e {")},
+		"invalid/f.kdl": {Data: []byte("f")},
+	}
+
+	docs, err := gokdl.ParseGlob(fsys, "*.kdl")
+	if err != nil {
+		t.Fatalf("expected no error but was: %s", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents but was: %d", len(docs))
+	}
+	for name, expected := range map[string]string{"a.kdl": "a\n", "b.kdl": "b 1\n"} {
+		if docs[name].String() != expected || docs[name].Filename() != name {
+			t.Fatalf("expected document %s to be %q but was %q", name, expected, docs[name])
+		}
+	}
+
+	_, err = gokdl.ParseGlob(fsys, "invalid/*.kdl")
+	var perr *gokdl.ParseError
+	if !errors.As(err, &perr) || perr.Pos.Filename != "invalid/e.kdl" {
+		t.Fatalf("expected a *ParseError with the file name but was: %v", err)
+	}
+
+	if _, err = gokdl.ParseGlob(fsys, "["); !errors.Is(err, path.ErrBadPattern) {
+		t.Fatalf("expected a bad pattern error but was: %v", err)
+	}
+}
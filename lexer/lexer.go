@@ -0,0 +1,377 @@
+// Package lexer splits KDL documents into classified tokens.
+//
+// Unlike the parser the lexer never fails: every byte of the input
+// is part of exactly one token, so concatenating the text of all
+// tokens results in the input. This makes it suitable for syntax
+// highlighting and other tools that work on the source of documents,
+// even if they are incomplete or invalid.
+package lexer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pkg "github.com/lunjon/gokdl/internal"
+)
+
+// Kind is the classification of a token.
+type Kind int
+
+const (
+	EOF Kind = iota
+	// Invalid is any input that is not valid KDL,
+	// e.g. an unterminated string.
+	Invalid
+	// Whitespace excluding newlines.
+	Whitespace
+	// Newline is a single newline, where CRLF counts as one.
+	Newline
+	// Comment is a line comment, multiline comment,
+	// or the slash-dash marker (/-).
+	Comment
+	// Identifier is a bare identifier, e.g. a node name.
+	Identifier
+	// String is a quoted string, including the quotes.
+	String
+	// RawString is a raw string, including the delimiters,
+	// e.g. r#"raw"#.
+	RawString
+	// Number is any integer or float.
+	Number
+	// Keyword is true, false and null.
+	Keyword
+	// Annotation is a type annotation including
+	// the parentheses, e.g. (u8).
+	Annotation
+	// Punctuation is any of { } ; = and \.
+	Punctuation
+)
+
+var kindNames = map[Kind]string{
+	EOF:         "EOF",
+	Invalid:     "Invalid",
+	Whitespace:  "Whitespace",
+	Newline:     "Newline",
+	Comment:     "Comment",
+	Identifier:  "Identifier",
+	String:      "String",
+	RawString:   "RawString",
+	Number:      "Number",
+	Keyword:     "Keyword",
+	Annotation:  "Annotation",
+	Punctuation: "Punctuation",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Token is a classified part of the input.
+type Token struct {
+	Kind Kind
+	// Text of the token, sliced from the input.
+	Text string
+	// Start and End are the byte offsets of
+	// the token in the input: input[Start:End].
+	Start int
+	End   int
+}
+
+// Lexer splits an input into tokens.
+type Lexer struct {
+	src string
+	pos int
+}
+
+// New returns a lexer of src.
+func New(src string) *Lexer {
+	return &Lexer{src: src}
+}
+
+// Tokenize returns all tokens in src, excluding EOF.
+func Tokenize(src string) []Token {
+	var tokens []Token
+	l := New(src)
+	for {
+		token := l.Next()
+		if token.Kind == EOF {
+			return tokens
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// Next returns the next token. At the end of the
+// input a token of kind EOF is returned.
+func (l *Lexer) Next() Token {
+	start := l.pos
+	kind := l.scan()
+	return Token{
+		Kind:  kind,
+		Text:  l.src[start:l.pos],
+		Start: start,
+		End:   l.pos,
+	}
+}
+
+func (l *Lexer) scan() Kind {
+	r := l.peek(0)
+	switch {
+	case l.pos >= len(l.src):
+		return EOF
+	case pkg.IsNewline(r):
+		l.next()
+		if r == '\r' && l.peek(0) == '\n' {
+			l.next()
+		}
+		return Newline
+	case unicode.IsSpace(r) || (r == pkg.BOM && l.pos == 0):
+		// A leading byte order mark is treated as whitespace
+		l.next()
+		l.skipWhile(func(r rune) bool {
+			return unicode.IsSpace(r) && !pkg.IsNewline(r)
+		})
+		return Whitespace
+	case r == '/':
+		return l.scanSlash()
+	case r == '"':
+		return l.scanString()
+	case r == 'r' && (l.peek(1) == '"' || l.peek(1) == '#'):
+		if kind, ok := l.scanRawString(1); ok {
+			return kind
+		}
+		return l.scanIdentifier()
+	case r == '#':
+		return l.scanHash()
+	case r == '(':
+		return l.scanAnnotation()
+	case r == '{' || r == '}' || r == ';' || r == '=' || r == '\\':
+		l.next()
+		return Punctuation
+	case isNumberStart(r, l.peek(1)):
+		return l.scanNumber()
+	case isIdentifier(r):
+		return l.scanIdentifier()
+	default:
+		l.next()
+		return Invalid
+	}
+}
+
+func (l *Lexer) scanSlash() Kind {
+	switch l.peek(1) {
+	case '/':
+		l.skipWhile(func(r rune) bool {
+			return !pkg.IsNewline(r)
+		})
+		return Comment
+	case '*':
+		return l.scanMultilineComment()
+	case '-':
+		l.pos += 2
+		return Comment
+	default:
+		l.next()
+		return Invalid
+	}
+}
+
+// scanMultilineComment scans a, possibly nested, multiline comment.
+func (l *Lexer) scanMultilineComment() Kind {
+	l.pos += 2
+	depth := 1
+	for depth > 0 {
+		if l.pos >= len(l.src) {
+			return Invalid
+		}
+
+		if l.peek(0) == '/' && l.peek(1) == '*' {
+			depth++
+			l.pos += 2
+		} else if l.peek(0) == '*' && l.peek(1) == '/' {
+			depth--
+			l.pos += 2
+		} else {
+			l.next()
+		}
+	}
+	return Comment
+}
+
+func (l *Lexer) scanString() Kind {
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		return l.scanMultilineString()
+	}
+
+	l.next()
+	for l.pos < len(l.src) {
+		switch l.next() {
+		case '\\':
+			if l.pos < len(l.src) {
+				l.next()
+			}
+		case '"':
+			return String
+		}
+	}
+	return Invalid
+}
+
+// scanMultilineString scans a string in triple quotes.
+func (l *Lexer) scanMultilineString() Kind {
+	l.pos += 3
+	for l.pos < len(l.src) {
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			l.pos += 3
+			return String
+		}
+		if l.next() == '\\' && l.pos < len(l.src) {
+			l.next()
+		}
+	}
+	return Invalid
+}
+
+// scanRawString scans a raw string that starts after skipping
+// `offset` bytes, i.e. 1 for r"raw" and 0 for #"raw"#.
+// It reports false, without consuming anything, if there
+// is no raw string at the current position.
+func (l *Lexer) scanRawString(offset int) (Kind, bool) {
+	start := l.pos
+	l.pos += offset
+
+	hashes := 0
+	for l.peek(0) == '#' {
+		hashes++
+		l.pos++
+	}
+
+	if l.peek(0) != '"' || (offset == 0 && hashes == 0) {
+		l.pos = start
+		return Invalid, false
+	}
+	l.pos++
+
+	for l.pos < len(l.src) {
+		if l.next() != '"' {
+			continue
+		}
+
+		count := 0
+		for count < hashes && l.peek(0) == '#' {
+			count++
+			l.pos++
+		}
+		if count == hashes {
+			return RawString, true
+		}
+	}
+	return Invalid, true
+}
+
+// scanHash scans a raw string starting with #.
+func (l *Lexer) scanHash() Kind {
+	if kind, ok := l.scanRawString(0); ok {
+		return kind
+	}
+
+	// Anything else, e.g. #name or #true, is invalid
+	l.next()
+	l.skipWhile(isIdentifier)
+	return Invalid
+}
+
+// scanAnnotation scans a type annotation, e.g. (u8) or ("type").
+// If there is no valid type annotation only the parenthesis
+// is scanned, as punctuation.
+func (l *Lexer) scanAnnotation() Kind {
+	start := l.pos
+	l.next()
+
+	var kind Kind
+	switch r := l.peek(0); {
+	case r == '"':
+		kind = l.scanString()
+	case isIdentifier(r):
+		kind = l.scanIdentifier()
+	}
+
+	if (kind == String || kind == Identifier || kind == Keyword) && l.peek(0) == ')' {
+		l.next()
+		return Annotation
+	}
+
+	l.pos = start + 1
+	return Punctuation
+}
+
+func (l *Lexer) scanNumber() Kind {
+	start := l.pos
+	l.skipWhile(isIdentifier)
+	if isNumber(l.src[start:l.pos]) {
+		return Number
+	}
+	return Invalid
+}
+
+func (l *Lexer) scanIdentifier() Kind {
+	start := l.pos
+	l.skipWhile(isIdentifier)
+	switch text := l.src[start:l.pos]; {
+	case text == "true" || text == "false" || text == "null":
+		return Keyword
+	case pkg.IsBareIdent(text):
+		return Identifier
+	default:
+		// E.g. .5 or inf
+		return Invalid
+	}
+}
+
+// peek returns the rune n runes ahead, without consuming anything.
+// It returns utf8.RuneError at the end of the input.
+func (l *Lexer) peek(n int) rune {
+	pos := l.pos
+	for i := 0; ; i++ {
+		if pos >= len(l.src) {
+			return utf8.RuneError
+		}
+
+		r, size := utf8.DecodeRuneInString(l.src[pos:])
+		if i == n {
+			return r
+		}
+		pos += size
+	}
+}
+
+// next consumes and returns the next rune.
+// Invalid UTF-8 is consumed a single byte at a time.
+func (l *Lexer) next() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+	l.pos += size
+	return r
+}
+
+func (l *Lexer) skipWhile(pred func(rune) bool) {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !pred(r) || (r == utf8.RuneError && size == 1) {
+			return
+		}
+		l.pos += size
+	}
+}
+
+func isIdentifier(r rune) bool {
+	return pkg.IsIdentifier(r) && r != utf8.RuneError
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func isNumberStart(r, next rune) bool {
+	return isDigit(r) || ((r == '-' || r == '+') && isDigit(next))
+}
@@ -0,0 +1,124 @@
+package lexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type kindText struct {
+	kind Kind
+	text string
+}
+
+func TestLexer(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []kindText
+	}{
+		{"identifier", "node", []kindText{{Identifier, "node"}}},
+		{"identifier with symbols", "-this::WORKS?", []kindText{{Identifier, "-this::WORKS?"}}},
+		{"whitespace", " \t node", []kindText{{Whitespace, " \t "}, {Identifier, "node"}}},
+		{"newlines", "a\r\nb\n\nc", []kindText{
+			{Identifier, "a"}, {Newline, "\r\n"}, {Identifier, "b"},
+			{Newline, "\n"}, {Newline, "\n"}, {Identifier, "c"},
+		}},
+		{"line comment", "a // comment\nb", []kindText{
+			{Identifier, "a"}, {Whitespace, " "}, {Comment, "// comment"},
+			{Newline, "\n"}, {Identifier, "b"},
+		}},
+		{"multiline comment", "/* a\nb */", []kindText{{Comment, "/* a\nb */"}}},
+		{"nested multiline comment", "/* a /* b */ c */x", []kindText{
+			{Comment, "/* a /* b */ c */"}, {Identifier, "x"},
+		}},
+		{"unterminated multiline comment", "/* a", []kindText{{Invalid, "/* a"}}},
+		{"slash-dash", "/-node", []kindText{{Comment, "/-"}, {Identifier, "node"}}},
+		{"string", `"a \"b\" c"`, []kindText{{String, `"a \"b\" c"`}}},
+		{"unterminated string", `"abc`, []kindText{{Invalid, `"abc`}}},
+		{"empty string", `"" ""`, []kindText{{String, `""`}, {Whitespace, " "}, {String, `""`}}},
+		{"multi-line string", "\"\"\"\n  a \"b\" \\\"\"\"\n  \"\"\"x", []kindText{
+			{String, "\"\"\"\n  a \"b\" \\\"\"\"\n  \"\"\""}, {Identifier, "x"},
+		}},
+		{"unterminated multi-line string", "\"\"\"\n  a\"\"", []kindText{{Invalid, "\"\"\"\n  a\"\""}}},
+		{"raw multi-line string", "#\"\"\"\n  \"\"\"\n  \"\"\"#", []kindText{{RawString, "#\"\"\"\n  \"\"\"\n  \"\"\"#"}}},
+		{"raw string", `r"a\b"`, []kindText{{RawString, `r"a\b"`}}},
+		{"raw string hash", `r##"a"#b"##`, []kindText{{RawString, `r##"a"#b"##`}}},
+		{"raw string without r", `#"a"#`, []kindText{{RawString, `#"a"#`}}},
+		{"identifier starting with r", "rust", []kindText{{Identifier, "rust"}}},
+		{"integer", "-1_000", []kindText{{Number, "-1_000"}}},
+		{"float", "+1.5e-10", []kindText{{Number, "+1.5e-10"}}},
+		{"hex", "0xdead_beef", []kindText{{Number, "0xdead_beef"}}},
+		{"octal", "0o755", []kindText{{Number, "0o755"}}},
+		{"binary", "0b0101", []kindText{{Number, "0b0101"}}},
+		{"invalid number", "1abc", []kindText{{Invalid, "1abc"}}},
+		{"keywords", "true false null", []kindText{
+			{Keyword, "true"}, {Whitespace, " "}, {Keyword, "false"}, {Whitespace, " "}, {Keyword, "null"},
+		}},
+		{"hash keywords", "#true #-inf", []kindText{{Invalid, "#true"}, {Whitespace, " "}, {Invalid, "#-inf"}}},
+		{"keyword prefix", "trueish", []kindText{{Identifier, "trueish"}}},
+		{"signed identifier", "-.a", []kindText{{Identifier, "-.a"}}},
+		{"dot and digit", ".5", []kindText{{Invalid, ".5"}}},
+		{"reserved keyword", "inf", []kindText{{Invalid, "inf"}}},
+		{"hash identifier", "a#b", []kindText{{Identifier, "a"}, {Invalid, "#b"}}},
+		{"annotation", "(u8)255", []kindText{{Annotation, "(u8)"}, {Number, "255"}}},
+		{"quoted annotation", `("my type")node`, []kindText{{Annotation, `("my type")`}, {Identifier, "node"}}},
+		{"unclosed annotation", "(u8 1", []kindText{
+			{Punctuation, "("}, {Identifier, "u8"}, {Whitespace, " "}, {Number, "1"},
+		}},
+		{"punctuation", `a=1;{}\`, []kindText{
+			{Identifier, "a"}, {Punctuation, "="}, {Number, "1"}, {Punctuation, ";"},
+			{Punctuation, "{"}, {Punctuation, "}"}, {Punctuation, `\`},
+		}},
+		{"invalid characters", "a[b]", []kindText{
+			{Identifier, "a"}, {Invalid, "["}, {Identifier, "b"}, {Invalid, "]"},
+		}},
+		{"invalid utf-8", "a\xffb", []kindText{{Identifier, "a"}, {Invalid, "\xff"}, {Identifier, "b"}}},
+		{"byte order mark", "\uFEFFnode", []kindText{{Whitespace, "\uFEFF"}, {Identifier, "node"}}},
+		{"byte order mark not at start", "a\uFEFF", []kindText{{Identifier, "a"}, {Invalid, "\uFEFF"}}},
+		{"disallowed code point", "a\u202eb", []kindText{{Identifier, "a"}, {Invalid, "\u202e"}, {Identifier, "b"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actual []kindText
+			for _, token := range Tokenize(test.src) {
+				actual = append(actual, kindText{token.Kind, token.Text})
+			}
+			require.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestLexerOffsets(t *testing.T) {
+	src := "node \"åäö\" key=1"
+	for _, token := range Tokenize(src) {
+		require.Equal(t, token.Text, src[token.Start:token.End])
+	}
+}
+
+func FuzzLexer(f *testing.F) {
+	filenames, err := filepath.Glob("../testdata/*.kdl")
+	require.NoError(f, err)
+	for _, filename := range filenames {
+		bs, err := os.ReadFile(filename)
+		require.NoError(f, err)
+		f.Add(string(bs))
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		// Property: the tokens cover the input without gaps
+		var b strings.Builder
+		end := 0
+		for _, token := range Tokenize(src) {
+			require.Equal(t, end, token.Start, "gap before token %v", token)
+			require.Less(t, token.Start, token.End, "empty token %v", token)
+			b.WriteString(token.Text)
+			end = token.End
+		}
+		require.Equal(t, src, b.String())
+	})
+}
@@ -0,0 +1,15 @@
+package lexer
+
+import "regexp"
+
+var numberPattern = regexp.MustCompile(`^[+-]?(` +
+	`0x[0-9a-fA-F][0-9a-fA-F_]*|` +
+	`0o[0-7][0-7_]*|` +
+	`0b[01][01_]*|` +
+	`[0-9][0-9_]*(\.[0-9][0-9_]*)?([eE][+-]?[0-9][0-9_]*)?` +
+	`)$`)
+
+// isNumber reports whether s is a valid number.
+func isNumber(s string) bool {
+	return numberPattern.MatchString(s)
+}
@@ -7,6 +7,8 @@ type Node struct {
 	// have children it is an empty list.
 	Children []Node
 	// Properties of the node.
+	// A property occurs once, with the rightmost value in
+	// the document, unless parsed with KeepDuplicateProps.
 	Props []Prop
 	// Arguments of the node.
 	Args []Arg
@@ -15,3 +17,58 @@ type Node struct {
 	// exists for this node.
 	TypeAnnotation TypeAnnotation
 }
+
+// PropMap returns the properties of the node by name.
+// If a property occurs more than once the rightmost wins.
+func (n Node) PropMap() PropMap {
+	m := PropMap{props: make(map[string]Prop, len(n.Props))}
+	for _, p := range n.Props {
+		if _, ok := m.props[p.Name]; !ok {
+			m.names = append(m.names, p.Name)
+		}
+		m.props[p.Name] = p
+	}
+	return m
+}
+
+// PropMap is a map of properties by name that
+// keeps the order in which they were inserted.
+type PropMap struct {
+	names []string
+	props map[string]Prop
+}
+
+// Len returns the number of properties in the map.
+func (m PropMap) Len() int {
+	return len(m.names)
+}
+
+// Get returns the property with the name,
+// and false if there is no such property.
+func (m PropMap) Get(name string) (Prop, bool) {
+	p, ok := m.props[name]
+	return p, ok
+}
+
+// Names returns the names of the properties in insertion order.
+func (m PropMap) Names() []string {
+	return append([]string(nil), m.names...)
+}
+
+// Props returns the properties in insertion order.
+func (m PropMap) Props() []Prop {
+	props := make([]Prop, len(m.names))
+	for i, name := range m.names {
+		props[i] = m.props[name]
+	}
+	return props
+}
+
+func propIndex(props []Prop, name string) int {
+	for i, p := range props {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,43 @@
+package gokdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodePropMap(t *testing.T) {
+	// Arrange
+	doc, err := ParseString(`node b=1 a=2 b=3 c=4`, KeepDuplicateProps())
+	require.NoError(t, err)
+
+	// Act
+	m := doc.Nodes()[0].PropMap()
+
+	// Assert
+	require.Equal(t, 3, m.Len())
+	require.Equal(t, []string{"b", "a", "c"}, m.Names())
+	require.Equal(t, []Prop{
+		{Name: "b", Value: int64(3)},
+		{Name: "a", Value: int64(2)},
+		{Name: "c", Value: int64(4)},
+	}, m.Props())
+
+	b, ok := m.Get("b")
+	require.True(t, ok)
+	require.Equal(t, int64(3), b.Value)
+
+	_, ok = m.Get("missing")
+	require.False(t, ok)
+}
+
+func TestNodePropMapEmpty(t *testing.T) {
+	// Act
+	m := Node{Name: "node"}.PropMap()
+
+	// Assert
+	require.Equal(t, 0, m.Len())
+	require.Empty(t, m.Props())
+	_, ok := m.Get("a")
+	require.False(t, ok)
+}
@@ -0,0 +1,81 @@
+package gokdl
+
+import "io/fs"
+
+// Option configures how a document is parsed.
+type Option func(*options)
+
+type options struct {
+	keepDuplicateProps bool
+	keepSlashDash      bool
+	includeFS          fs.FS
+	includeNode        string
+	// Used only when decoding.
+	disallowUnknownFields bool
+}
+
+func newOptions(opts []Option) options {
+	o := options{includeNode: "include"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// KeepDuplicateProps keeps every occurrence of a property on
+// a node, in the order they appear. By default only the rightmost
+// occurrence is kept, as the spec says, which hides duplicates
+// from e.g. a linter.
+func KeepDuplicateProps() Option {
+	return func(o *options) {
+		o.keepDuplicateProps = true
+	}
+}
+
+// RetainSlashDash makes a Reader read the items that are commented
+// out using slash-dash, each preceded by a SlashDash event, so that
+// tools working on the source can see them just like comments.
+// By default they are skipped. It has no effect when parsing
+// into nodes, since the nodes never contain them.
+func RetainSlashDash() Option {
+	return func(o *options) {
+		o.keepSlashDash = true
+	}
+}
+
+// IncludeFiles enables include directives, i.e. nodes like
+// include "path.kdl", which are replaced by the nodes of the
+// file at the path. The files are opened in fsys, e.g. an embed.FS
+// or an os.DirFS, and may in turn include other files.
+//
+// Paths are slash-separated and relative to the directory of
+// the including file, or to the root of fsys if they start with
+// a slash. A document parsed using ParseFS is at its name in
+// fsys, while other documents are treated as if they were at the
+// root of fsys.
+// A file that includes itself, directly or not, is an error.
+//
+// It has no effect on a Reader, which never resolves includes.
+func IncludeFiles(fsys fs.FS) Option {
+	return func(o *options) {
+		o.includeFS = fsys
+	}
+}
+
+// IncludeNode sets the name of the nodes that are include
+// directives when IncludeFiles is used. It is "include"
+// by default.
+func IncludeNode(name string) Option {
+	return func(o *options) {
+		o.includeNode = name
+	}
+}
+
+// DisallowUnknownFields makes Unmarshal and Decoder report the nodes,
+// properties and arguments that no field is decoded from as errors,
+// instead of ignoring them.
+func DisallowUnknownFields() Option {
+	return func(o *options) {
+		o.disallowUnknownFields = true
+	}
+}
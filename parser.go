@@ -1,35 +1,193 @@
 package gokdl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	pkg "github.com/lunjon/gokdl/internal"
 )
 
-var newlinesToQuoted = map[string]string{
-	"\n":     "\\n",     // newline
-	"\r":     "\\r",     // carriage return
-	"\r\n":   "\\r\\n",  // carriage return newline
-	"\f":     "\\f",     // form feed
-	"\u0085": "\\u0085", // next line
-	"\u2028": "\\u2028", // line separator
-	"\u2029": "\\u2029", // paragraph separator
-}
+// Runes that are newlines.
+const newlineRunes = "\n\r\f\u0085\u2028\u2029"
 
 func isNewline(lit string) bool {
-	for nl := range newlinesToQuoted {
-		if strings.Contains(lit, nl) {
-			return true
+	return strings.ContainsAny(lit, newlineRunes)
+}
+
+type parseContext struct {
+	ctx context.Context
+	// Receives the events of the document.
+	handler handler
+	// Greater than zero while parsing items that are commented
+	// out using slash-dash. Their events are dropped unless
+	// keepSkipped is true.
+	skip        int
+	keepSkipped bool
+	// The name of the file being parsed, if any.
+	filename string
+}
+
+func (cx *parseContext) emit(ev Event, pos pkg.Position) {
+	if cx.skip == 0 || cx.keepSkipped {
+		cx.handler.handle(ev, newPosition(pos, cx.filename))
+	}
+}
+
+// startSkip starts skipping the next item, which is commented
+// out by the slash-dash at pos, until endSkip is called.
+func (cx *parseContext) startSkip(pos pkg.Position) {
+	if cx.keepSkipped {
+		cx.emit(SlashDash{}, pos)
+	}
+	cx.skip++
+}
+
+func (cx *parseContext) endSkip() {
+	cx.skip--
+}
+
+// handler receives the events of a document while it is parsed.
+type handler interface {
+	handle(ev Event, pos Position)
+}
+
+// treeBuilder is a handler that builds
+// the nodes of the document from its events.
+type treeBuilder struct {
+	nodes []Node
+	// The nodes that are started but not yet ended.
+	stack []openNode
+	// Keep all occurrences of a property instead of the rightmost.
+	keepDuplicateProps bool
+	// Record the positions of the nodes, and of their arguments
+	// and properties, in roots, which mirrors nodes.
+	positions bool
+	roots     []*posNode
+	// Resolves include directives, i.e. nodes named includeNode,
+	// into the builder of the included document, whose nodes
+	// replace them. It is nil unless includes are enabled.
+	include     func(n Node, pos Position) (*treeBuilder, error)
+	includeNode string
+	// The first error from resolving an include directive,
+	// after which all events are ignored.
+	err error
+}
+
+// posNode is a node with the positions of
+// itself and its arguments and properties.
+type posNode struct {
+	node     Node
+	pos      Position
+	argPos   []Position
+	propPos  []Position
+	children []*posNode
+}
+
+// openNode is a node that is started but not yet ended.
+type openNode struct {
+	posNode
+	// The indices of the properties by name, once
+	// the node has more than maxScannedProps properties.
+	propIndices map[string]int
+}
+
+// maxScannedProps is the number of properties of a node that
+// are searched for a name before a map of them is built, which
+// is only worth it for nodes with many properties.
+const maxScannedProps = 8
+
+// propIndex returns the index of the property with the name, or -1.
+// The properties must have distinct names.
+func (n *openNode) propIndex(name string) int {
+	if n.propIndices == nil {
+		if len(n.node.Props) <= maxScannedProps {
+			return propIndex(n.node.Props, name)
+		}
+		n.propIndices = make(map[string]int, len(n.node.Props))
+		for i, p := range n.node.Props {
+			n.propIndices[p.Name] = i
 		}
 	}
-	return false
+	if i, ok := n.propIndices[name]; ok {
+		return i
+	}
+	return -1
 }
 
-type parseContext struct{}
+func (b *treeBuilder) handle(ev Event, pos Position) {
+	if b.err != nil {
+		return
+	}
+
+	switch ev := ev.(type) {
+	case StartNode:
+		b.stack = append(b.stack, openNode{posNode: posNode{
+			node: Node{
+				Name:           ev.Name,
+				Children:       []Node{},
+				Props:          []Prop{},
+				Args:           []Arg{},
+				TypeAnnotation: ev.TypeAnnotation,
+			},
+			pos: pos,
+		}})
+	case Arg:
+		n := &b.stack[len(b.stack)-1]
+		n.node.Args = append(n.node.Args, ev)
+		if b.positions {
+			n.argPos = append(n.argPos, pos)
+		}
+	case Prop:
+		n := &b.stack[len(b.stack)-1]
+		if !b.keepDuplicateProps {
+			// The rightmost property wins, in the place of the first
+			if i := n.propIndex(ev.Name); i >= 0 {
+				n.node.Props[i] = ev
+				if b.positions {
+					n.propPos[i] = pos
+				}
+				break
+			}
+		}
+		n.node.Props = append(n.node.Props, ev)
+		if n.propIndices != nil {
+			n.propIndices[ev.Name] = len(n.node.Props) - 1
+		}
+		if b.positions {
+			n.propPos = append(n.propPos, pos)
+		}
+	case EndNode:
+		n := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+		nodes := []Node{n.node}
+		var roots []*posNode
+		if b.positions {
+			pn := n.posNode
+			roots = []*posNode{&pn}
+		}
+		if b.include != nil && n.node.Name == b.includeNode {
+			var included *treeBuilder
+			if included, b.err = b.include(n.node, n.pos); b.err != nil {
+				return
+			}
+			nodes, roots = included.nodes, included.roots
+		}
+
+		if len(b.stack) == 0 {
+			b.nodes = append(b.nodes, nodes...)
+			b.roots = append(b.roots, roots...)
+		} else {
+			parent := &b.stack[len(b.stack)-1]
+			parent.node.Children = append(parent.node.Children, nodes...)
+			parent.children = append(parent.children, roots...)
+		}
+	}
+}
 
 // The type responsible for parsing the documents.
 // The parser relies on the Scanner (internal) for
@@ -37,45 +195,147 @@ type parseContext struct{}
 //
 // Specification: https://github.com/kdl-org/kdl/blob/main/SPEC.md
 type parser struct {
-	sc *pkg.Scanner
+	ctx  context.Context
+	sc   *pkg.Scanner
+	opts options
+	// The name of the file being parsed, if any.
+	filename string
+	// The path of the file being parsed in the file system of
+	// includes, if any, and the paths of the files that include
+	// it, from the outermost, to detect cycles.
+	path     string
+	includes []string
 }
 
 func newParser(src io.Reader) *parser {
+	return newParserContext(context.Background(), src)
+}
+
+func newParserContext(ctx context.Context, src io.Reader) *parser {
 	return &parser{
-		sc: pkg.NewScanner(src),
+		ctx: ctx,
+		sc:  pkg.NewScannerContext(ctx, src),
+	}
+}
+
+func newParserString(ctx context.Context, src string) *parser {
+	return &parser{
+		ctx: ctx,
+		sc:  pkg.NewScannerString(ctx, src),
 	}
 }
 
 func (p *parser) parse() (Doc, error) {
-	cx := &parseContext{}
-	nodes, err := parseScope(cx, p.sc, false)
+	builder, err := p.build(false)
+	if err != nil {
+		return Doc{}, err
+	}
 
 	return Doc{
-		nodes: nodes,
-	}, err
+		nodes:    builder.nodes,
+		filename: p.filename,
+	}, nil
 }
 
-// Parses a root or child scope (inside a node).
-func parseScope(cx *parseContext, sc *pkg.Scanner, isChild bool) ([]Node, error) {
-	nodes := []Node{} // The nodes accumulated in this scope
-	done := false     // When true, parsing of the scope (root or children) is done
+// build parses the document into the nodes of a treeBuilder,
+// which also records their positions if positions is true.
+func (p *parser) build(positions bool) (*treeBuilder, error) {
+	builder := &treeBuilder{
+		nodes:              []Node{},
+		keepDuplicateProps: p.opts.keepDuplicateProps,
+		positions:          positions,
+	}
+	if p.opts.includeFS != nil {
+		builder.include = func(n Node, pos Position) (*treeBuilder, error) {
+			return p.include(n, pos, positions)
+		}
+		builder.includeNode = p.opts.includeNode
+	}
+	// The nodes never contain items commented out using slash-dash
+	p.opts.keepSlashDash = false
+	err := p.run(builder)
+	if builder.err != nil {
+		// The directive precedes any error in the rest of the document
+		return nil, builder.err
+	} else if err != nil {
+		return nil, err
+	}
+	return builder, nil
+}
 
-	var typeAnnot string
+// run parses the document and passes its events to h.
+func (p *parser) run(h handler) error {
+	cx := &parseContext{
+		ctx:         p.ctx,
+		handler:     h,
+		keepSkipped: p.opts.keepSlashDash,
+		filename:    p.filename,
+	}
+	err := parseScope(cx, p.sc, false)
+	if scErr := p.sc.Err(); scErr != nil {
+		// The scanner reports read errors, including a done context,
+		// as EOF. Thus they are the cause of any error from parsing.
+		err = scErr
+	}
 
-	appendNode := func(n Node) {
-		if typeAnnot != "" {
-			n.TypeAnnotation = TypeAnnotation(typeAnnot)
-			typeAnnot = ""
+	if err != nil {
+		pos := p.sc.Pos()
+		var perr *posError
+		if errors.As(err, &perr) {
+			pos, err = perr.pos, perr.err
+		}
+		return &ParseError{
+			Pos: newPosition(pos, p.filename),
+			Err: err,
 		}
-		nodes = append(nodes, n)
 	}
+	return nil
+}
+
+// posError is an error that is reported at pos instead of at
+// the current position of the scanner, e.g. at the start of
+// a comment that is never closed.
+type posError struct {
+	pos pkg.Position
+	err error
+}
+
+func (e *posError) Error() string {
+	return e.err.Error()
+}
+
+func (e *posError) Unwrap() error {
+	return e.err
+}
+
+// Parses a root or child scope (inside a node).
+func parseScope(cx *parseContext, sc *pkg.Scanner, isChild bool) error {
+	done := false // When true, parsing of the scope (root or children) is done
+
+	var typeAnnot string
+	var start pkg.Position // Start of the next node, including the type annotation
+	slashdash := false     // The next node is commented out using slash-dash
 
 	for !done {
+		if err := cx.ctx.Err(); err != nil {
+			return err
+		}
+
 		token, lit := sc.Scan()
+		if slashdash && pkg.IsAnyOf(token, pkg.EOF, pkg.SEMICOLON, pkg.CBRACK_CLOSE) {
+			return fmt.Errorf("expected a node after slash-dash comment")
+		}
 		if token == pkg.EOF {
+			if isChild {
+				return fmt.Errorf("unexpected end of document: missing }")
+			}
 			break
 		}
 
+		if typeAnnot == "" {
+			start = sc.TokenPos()
+		}
+
 		switch token {
 		case pkg.WS:
 			continue
@@ -85,29 +345,28 @@ func parseScope(cx *parseContext, sc *pkg.Scanner, isChild bool) ([]Node, error)
 			if isChild {
 				done = true
 			} else {
-				return nil, fmt.Errorf("unexpected token: %s", lit)
+				return fmt.Errorf("unexpected token: %s", lit)
 			}
 		case pkg.COMMENT_LINE:
-			sc.ScanLine()
+			text := sc.ScanLine()
+			cx.emit(Comment{Text: lit + text}, sc.TokenPos())
 		case pkg.COMMENT_MUL_OPEN:
-			if err := scanMultilineComment(cx, sc); err != nil {
-				return nil, err
+			text, err := scanMultilineComment(cx, sc)
+			if err != nil {
+				return err
 			}
+			cx.emit(Comment{Text: text}, sc.TokenPos())
 		case pkg.COMMENT_SD:
-			// Parse the following content as node and ignore the result
-			nextToken, _ := sc.Scan()
-			if pkg.IsInitialIdentToken(nextToken) {
-				text := sc.ScanBareIdent()
-				if _, err := scanNode(cx, sc, text); err != nil {
-					return nil, fmt.Errorf("expected a node after slash-dash comment: %s", err)
-				}
-			} else {
-				return nil, fmt.Errorf("expected a node after slash-dash comment")
+			// The next node is parsed but its events are skipped
+			if slashdash || typeAnnot != "" {
+				return fmt.Errorf("unexpected slash-dash comment")
 			}
+			cx.startSkip(sc.TokenPos())
+			slashdash = true
 		case pkg.PAREN_OPEN:
 			annot, err := scanTypeAnnotation(cx, sc)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			typeAnnot = annot
 		case pkg.QUOTE, pkg.RAWSTR_OPEN, pkg.RAWSTR_HASH_OPEN, pkg.RAWSTR_HASH_CLOSE:
@@ -128,122 +387,188 @@ func parseScope(cx *parseContext, sc *pkg.Scanner, isChild bool) ([]Node, error)
 			}
 
 			if err != nil {
-				return nil, err
+				return err
 			}
 
-			node, err := scanNode(cx, sc, str)
-			if err != nil {
-				return nil, err
+			if err := scanNode(cx, sc, str, typeAnnot, start); err != nil {
+				return err
+			}
+			typeAnnot = ""
+			if slashdash {
+				cx.endSkip()
+				slashdash = false
 			}
-			appendNode(node)
 		default:
 			if pkg.IsInitialIdentToken(token) {
-				text := sc.ScanBareIdent()
-				node, err := scanNode(cx, sc, lit+text)
-				if err != nil {
-					return nil, err
+				name := lit + sc.ScanBareIdent()
+				if err := checkIdentifier(name); err != nil {
+					return err
+				}
+				if err := scanNode(cx, sc, name, typeAnnot, start); err != nil {
+					return err
+				}
+				typeAnnot = ""
+				if slashdash {
+					cx.endSkip()
+					slashdash = false
 				}
-				appendNode(node)
 			} else {
-				return nil, fmt.Errorf("unexpected token: %s", lit)
+				return fmt.Errorf("unexpected token: %s", lit)
 			}
 		}
 	}
 
-	return nodes, nil
+	return nil
 }
 
-func scanMultilineComment(cx *parseContext, sc *pkg.Scanner) error {
+// scanMultilineComment scans the rest of a, possibly nested,
+// multiline comment and returns the full comment.
+func scanMultilineComment(cx *parseContext, sc *pkg.Scanner) (string, error) {
+	buf := strings.Builder{}
+	buf.WriteString("/*")
+
+	// Comments nest, so the start of every comment
+	// that is not yet closed is kept
+	starts := []pkg.Position{sc.TokenPos()}
+
+	var prev rune
+	var prevPos pkg.Position
 	for {
-		token, _ := sc.Scan()
-		if token == pkg.EOF {
+		pos := sc.Pos()
+		ch := sc.ScanRune()
+		if ch == pkg.EOF_RUNE {
 			break
 		}
 
-		if token == pkg.COMMENT_MUL_CLOSE {
-			return nil
+		buf.WriteRune(ch)
+		switch {
+		case prev == '/' && ch == '*':
+			starts = append(starts, prevPos)
+			ch = 0 // The * can not also end a comment, as in /*/
+		case prev == '*' && ch == '/':
+			starts = starts[:len(starts)-1]
+			if len(starts) == 0 {
+				return buf.String(), nil
+			}
+			ch = 0 // The / can not also start a comment, as in */*
 		}
+		prev = ch
+		prevPos = pos
 	}
 
-	return fmt.Errorf("no closing of multiline comment")
+	return "", &posError{
+		pos: starts[len(starts)-1],
+		err: fmt.Errorf("unterminated comment: missing */"),
+	}
 }
 
-func scanNode(cx *parseContext, sc *pkg.Scanner, name string) (Node, error) {
+// scanNode scans the rest of a node, given its name and type annotation,
+// emitting the events of the node. The start is the position of the node.
+func scanNode(cx *parseContext, sc *pkg.Scanner, name, typeAnnot string, start pkg.Position) error {
 	// This function gets called immediately after an
 	// idenfitier was read. So just check that the following
 	// token is valid.
 	next, nextlit := sc.Scan()
 	if !pkg.IsAnyOf(next, pkg.EOF, pkg.WS, pkg.SEMICOLON, pkg.CBRACK_CLOSE) {
-		return Node{}, fmt.Errorf("unexpected token in identifier: %s", nextlit)
+		return fmt.Errorf("unexpected token in identifier: %s", nextlit)
 	}
 
 	sc.Unread()
 
-	children := []Node{}
-	args := []Arg{}
-	props := []Prop{}
+	cx.emit(StartNode{Name: name, TypeAnnotation: TypeAnnotation(typeAnnot)}, start)
 
 	done := false
-	skip := false // Used with slash-dash comments
+	slashdash := false // The next item is commented out using slash-dash
 
 	typeAnnotation := ""
+	var itemStart pkg.Position // Start of the next argument or property
+
+	// endItem is called when an item, i.e. an argument,
+	// a property or the children, has been scanned.
+	endItem := func() {
+		typeAnnotation = ""
+		if slashdash {
+			cx.endSkip()
+			slashdash = false
+		}
+	}
+
 	for !done {
 		token, lit := sc.Scan()
+		if slashdash && pkg.IsAnyOf(token, pkg.EOF, pkg.SEMICOLON, pkg.CBRACK_CLOSE) {
+			return fmt.Errorf("expected an argument, property or children after slash-dash comment")
+		}
+		if typeAnnotation != "" && pkg.IsAnyOf(token, pkg.EOF, pkg.BACKSLASH, pkg.SEMICOLON, pkg.CBRACK_OPEN, pkg.COMMENT_SD) {
+			return fmt.Errorf("unexpected type annotation")
+		}
 		if token == pkg.EOF {
 			break
 		}
 
-		if typeAnnotation != "" && pkg.IsAnyOf(token, pkg.BACKSLASH, pkg.SEMICOLON, pkg.CBRACK_OPEN) {
-			return Node{}, fmt.Errorf("unexpected type annotation")
+		if typeAnnotation == "" {
+			itemStart = sc.TokenPos()
 		}
 
 		switch token {
 		case pkg.BACKSLASH:
-			sc.ScanWhitespace()
+			_, ws := sc.ScanWhitespace()
+			if isNewline(ws) {
+				break
+			}
+			// A line comment can end the line of the continuation
+			if next, lit := sc.Scan(); next == pkg.COMMENT_LINE {
+				text := sc.ScanLine()
+				cx.emit(Comment{Text: lit + text}, sc.TokenPos())
+				sc.ScanWhitespace()
+			} else {
+				sc.Unread()
+			}
 		case pkg.SEMICOLON:
 			done = true
 		case pkg.WS:
-			if isNewline(lit) {
+			// The target of a slash-dash can be on a following line
+			if isNewline(lit) && !slashdash {
 				done = true
 			}
 		case pkg.COMMENT_LINE:
-			sc.ScanLine()
-			done = true
+			text := sc.ScanLine()
+			cx.emit(Comment{Text: lit + text}, sc.TokenPos())
+			done = !slashdash
 		case pkg.COMMENT_MUL_OPEN:
-			if err := scanMultilineComment(cx, sc); err != nil {
-				return Node{}, err
+			text, err := scanMultilineComment(cx, sc)
+			if err != nil {
+				return err
 			}
+			cx.emit(Comment{Text: text}, sc.TokenPos())
 		case pkg.COMMENT_SD:
-			// We need to continue to parse and ignore the next result.
-			skip = true
-			// typeAnnotation = ""
+			// The next item is scanned but its events are skipped
+			if slashdash {
+				return fmt.Errorf("unexpected slash-dash comment")
+			}
+			cx.startSkip(sc.TokenPos())
+			slashdash = true
 		case pkg.NUM_INT:
-			if skip {
-				skip = false
-				typeAnnotation = ""
-				continue
+			if err := checkNumberEnd(sc); err != nil {
+				return err
 			}
 
 			arg, err := newIntArg(lit, typeAnnotation)
 			if err != nil {
-				return Node{}, err
+				return err
 			}
-			args = append(args, arg)
-			typeAnnotation = ""
+			cx.emit(arg, itemStart)
+			endItem()
 		case pkg.NUM_FLOAT, pkg.NUM_SCI:
-			if skip {
-				skip = false
-				typeAnnotation = ""
-				continue
+			if err := checkNumberEnd(sc); err != nil {
+				return err
 			}
 
 			arg, err := newFloatArg(lit, typeAnnotation)
 			if err != nil {
-				return Node{}, err
+				return err
 			}
-
-			args = append(args, arg)
-			typeAnnotation = ""
+			cx.emit(arg, itemStart)
+			endItem()
 		case pkg.QUOTE, pkg.RAWSTR_OPEN, pkg.RAWSTR_HASH_OPEN, pkg.RAWSTR_HASH_CLOSE:
 			var str string
 			var err error
@@ -259,218 +584,266 @@ func scanNode(cx *parseContext, sc *pkg.Scanner, name string) (Node, error) {
 				str, err = scanRawStringHash(cx, sc, lit, typeAnnotation)
 			}
 			if err != nil {
-				return Node{}, err
+				return err
 			}
 
 			nextToken, _ := sc.Scan()
 			if nextToken == pkg.EQUAL {
 				prop, err := scanProp(cx, sc, str, typeAnnotation)
 				if err != nil {
-					return Node{}, err
+					return err
 				}
-
-				if !skip {
-					props = append(props, prop)
-				}
-				skip = false
+				cx.emit(prop, itemStart)
 			} else {
-				if !skip {
-					sc.Unread()
-					arg := newArg(str, TypeAnnotation(typeAnnotation))
-					args = append(args, arg)
-				}
-
-				skip = false
+				sc.Unread()
+				cx.emit(newArg(str, TypeAnnotation(typeAnnotation)), itemStart)
 			}
-
-			typeAnnotation = ""
+			endItem()
 		case pkg.CBRACK_OPEN:
-			ns, err := parseScope(cx, sc, true)
+			cx.emit(StartChildren{}, sc.TokenPos())
+			err := parseScope(cx, sc, true)
 			if err != nil {
-				return Node{}, err
-			}
-
-			if !skip {
-				children = append(children, ns...)
+				return err
 			}
-
-			skip = false
+			cx.emit(EndChildren{}, sc.TokenPos())
+			endItem()
 		case pkg.CBRACK_CLOSE:
+			// Closes the scope of the parent
+			sc.Unread()
 			done = true
 		case pkg.PAREN_OPEN:
 			annot, err := scanTypeAnnotation(cx, sc)
 			if err != nil {
-				return Node{}, err
+				return err
 			}
 			typeAnnotation = annot
 		default:
-			// At this point there are multiple cases that can happen:
-			// - The following value is a literal: null, true, false
-			//   - These should be treated as such
-			// - It is the start of a property name
-			//
-			// All the literals have valid initial identifier tokens.
-			// That is, n(ull), t(rue) and f(alse) can be the start
-			// of an identifier and NOT the literals.
-			//
-			// Thus we need to check the following tokens in order
-			// to decide what it is.
-
-			{ // Check literals
-				var value any
-				var ok bool
-
-				_, next := sc.ScanLetters()
-				next = lit + next
-
-				switch next {
-				case "null":
-					value = nil // Default for any...
-					ok = true
-				case "true":
-					value = true
-					ok = true
-				case "false":
-					value = false
-					ok = true
-				}
+			if !pkg.IsInitialIdentToken(token) {
+				return fmt.Errorf("unexpected token: %s", lit)
+			}
 
-				if ok {
-					if typeAnnotation != "" {
-						return Node{}, fmt.Errorf("unexpected type annotation")
-					}
-
-					if !skip {
-						args = append(args, newArg(value, ""))
-						skip = false
-					}
-					continue
-				} else {
-					sc.Unread()
+			// The identifier is either a keyword, which is a value,
+			// or the name of a property
+			id := lit + sc.ScanBareIdent()
+			if value, ok := keywordValue(id); ok {
+				if typeAnnotation != "" {
+					return fmt.Errorf("unexpected type annotation")
 				}
+
+				cx.emit(newArg(value, ""), itemStart)
+				endItem()
+				continue
 			}
 
-			if pkg.IsInitialIdentToken(token) {
-				id := sc.ScanBareIdent()
-				next, _ := sc.Scan()
-				if next != pkg.EQUAL {
-					return Node{}, fmt.Errorf("unexpected identifier")
-				}
+			if err := checkIdentifier(id); err != nil {
+				return err
+			}
 
-				prop, err := scanProp(cx, sc, lit+id, typeAnnotation)
-				if err != nil {
-					return Node{}, err
-				}
+			next, _ := sc.Scan()
+			if next != pkg.EQUAL {
+				return fmt.Errorf("unexpected identifier")
+			}
 
-				if !skip {
-					props = append(props, prop)
-				}
-				skip = false
-			} else {
-				return Node{}, fmt.Errorf("unexpected token: %s", lit)
+			prop, err := scanProp(cx, sc, id, typeAnnotation)
+			if err != nil {
+				return err
 			}
+			cx.emit(prop, itemStart)
+			endItem()
 		}
 	}
 
-	return Node{
-		Name:     name,
-		Children: children,
-		Props:    props,
-		Args:     args,
-	}, nil
+	cx.emit(EndNode{}, sc.Pos())
+	return nil
 }
 
 func scanString(cx *parseContext, sc *pkg.Scanner, typeAnnot string) (string, error) {
+	// When possible the value is sliced from the source,
+	// unless it contains escapes that must be decoded.
+	if sc.ScanPrefix(`""`) {
+		str, err := scanMultilineString(sc, 0)
+		if err != nil {
+			return "", err
+		}
+		return parseStringValue(str, typeAnnot)
+	}
+
+	start := sc.Pos().Offset
+	slicing := sc.CanSlice()
+
 	buf := strings.Builder{}
-	done := false
-	for !done {
-		token, lit := sc.Scan()
-		if token == pkg.EOF {
+	for {
+		ch := sc.ScanRune()
+		if ch == pkg.EOF_RUNE {
 			return "", fmt.Errorf("error reading string literal: reached EOF")
-		}
+		} else if ch == '"' {
+			break
+		} else if ch == '\\' {
+			if slicing {
+				buf.WriteString(sc.Slice(start, sc.Pos().Offset-1))
+				slicing = false
+			}
 
-		switch token {
-		case pkg.BACKSLASH:
-			next, nextLit := sc.Scan()
-			if next == pkg.QUOTE {
-				buf.WriteString(`\"`)
-			} else {
-				buf.WriteString(lit)
-				buf.WriteString(nextLit)
+			if err := scanEscape(sc, &buf); err != nil {
+				return "", err
 			}
-		case pkg.QUOTE:
-			done = true
-		case pkg.WS:
-			// Unquoted newline characters are invalid -> replace prior unquoting
-			res := lit
-			for nl, escaped := range newlinesToQuoted {
-				res = strings.ReplaceAll(res, nl, escaped)
-			}
-			buf.WriteString(res)
-		case pkg.RAWSTR_OPEN, pkg.RAWSTR_HASH_OPEN:
-			buf.WriteString(lit[:len(lit)-1])
-			done = true
-		default:
-			buf.WriteString(lit)
+		} else if !slicing {
+			buf.WriteRune(ch)
 		}
 	}
 
-	sss, err := strconv.Unquote("\"" + buf.String() + "\"")
-	if err != nil {
-		return "", err
+	if slicing {
+		return parseStringValue(sc.Slice(start, sc.Pos().Offset-1), typeAnnot)
 	}
-
-	return parseStringValue(sss, typeAnnot)
+	return parseStringValue(buf.String(), typeAnnot)
 }
 
 func scanRawString(cx *parseContext, sc *pkg.Scanner, typeAnnot string) (string, error) {
 	buf := strings.Builder{}
-	done := false
-	for !done {
-		token, lit := sc.Scan()
-		if token == pkg.EOF {
+	for {
+		ch := sc.ScanRune()
+		if ch == pkg.EOF_RUNE {
 			return "", fmt.Errorf("error reading raw string literal: reached EOF")
+		} else if ch == '"' {
+			break
 		}
-
-		switch token {
-		case pkg.QUOTE:
-			done = true
-		default:
-			buf.WriteString(lit)
-		}
+		buf.WriteRune(ch)
 	}
 
 	return parseStringValue(buf.String(), typeAnnot)
 }
 
 func scanRawStringHash(cx *parseContext, sc *pkg.Scanner, start, typeAnnot string) (string, error) {
-	end := strings.TrimPrefix(start, "r")
-	end = strings.TrimSuffix(end, `"`)
-	end = `"` + end
+	// The string is terminated by a quote followed by
+	// the same number of hashes as in the start.
+	hashes := strings.Count(start, "#")
+
+	if start[0] == '#' && sc.ScanPrefix(`""`) {
+		str, err := scanMultilineString(sc, hashes)
+		if err != nil {
+			return "", err
+		}
+		return parseStringValue(str, typeAnnot)
+	}
 
 	buf := strings.Builder{}
-	done := false
-	for !done {
-		token, lit := sc.Scan()
-		if token == pkg.EOF {
+	for {
+		ch := sc.ScanRune()
+		if ch == pkg.EOF_RUNE {
 			return "", fmt.Errorf("error reading raw string literal: reached EOF")
+		} else if ch != '"' {
+			buf.WriteRune(ch)
+			continue
 		}
 
-		switch token {
-		case pkg.RAWSTR_HASH_CLOSE:
-			if lit == end {
-				done = true
-			} else {
-				return "", fmt.Errorf("invalid terminal of raw string literal: %s", lit)
+		count := 0
+		for count < hashes {
+			next := sc.ScanRune()
+			if next != '#' {
+				if next != pkg.EOF_RUNE {
+					// Might be the start of the terminal
+					sc.UnscanRune()
+				}
+				break
 			}
-		default:
-			buf.WriteString(lit)
+			count++
 		}
+
+		if count == hashes {
+			break
+		}
+
+		buf.WriteRune('"')
+		buf.WriteString(strings.Repeat("#", count))
 	}
 
 	return parseStringValue(buf.String(), typeAnnot)
 }
 
+// scanMultilineString scans a multi-line string after the opening """,
+// or #""" if hashes > 0 in which case it is a raw string, and returns
+// its value. The content is dedented by the whitespace before the
+// closing """ and its newlines are normalized to \n.
+func scanMultilineString(sc *pkg.Scanner, hashes int) (string, error) {
+	ch := sc.ScanRune()
+	if ch == '\r' {
+		sc.ScanPrefix("\n")
+	} else if !pkg.IsNewline(ch) {
+		return "", fmt.Errorf(`invalid multi-line string: expected a newline after """`)
+	}
+
+	raw := hashes > 0
+	closing := `""` + strings.Repeat("#", hashes)
+
+	buf := strings.Builder{}
+	for {
+		ch := sc.ScanRune()
+		if ch == pkg.EOF_RUNE {
+			return "", fmt.Errorf("error reading multi-line string literal: reached EOF")
+		} else if ch == '"' && sc.ScanPrefix(closing) {
+			break
+		}
+
+		buf.WriteRune(ch)
+		if ch == '\\' && !raw {
+			// Escapes are decoded after dedenting, but
+			// an escaped quote must not close the string
+			next := sc.ScanRune()
+			if next == pkg.EOF_RUNE {
+				return "", fmt.Errorf("error reading multi-line string literal: reached EOF")
+			}
+			buf.WriteRune(next)
+		}
+	}
+
+	str, err := dedent(buf.String())
+	if err != nil || raw {
+		return str, err
+	}
+	return unescape(str)
+}
+
+// dedent removes the whitespace of the last line, i.e. the indentation
+// of the closing """, from the other lines of a multi-line string and
+// joins them with \n. Lines with only whitespace become empty.
+func dedent(s string) (string, error) {
+	lines := splitLines(s)
+	prefix := lines[len(lines)-1]
+	if strings.TrimFunc(prefix, unicode.IsSpace) != "" {
+		return "", fmt.Errorf(`invalid multi-line string: the closing """ must be on its own line`)
+	}
+
+	lines = lines[:len(lines)-1]
+	for i, line := range lines {
+		if strings.TrimFunc(line, unicode.IsSpace) == "" {
+			lines[i] = ""
+		} else if rest, ok := strings.CutPrefix(line, prefix); ok {
+			lines[i] = rest
+		} else {
+			return "", fmt.Errorf(`invalid multi-line string: line %d is not indented like the closing """`, i+1)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// splitLines splits s at each newline, where CRLF is a single newline.
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i, r := range s {
+		if i < start || !pkg.IsNewline(r) {
+			continue
+		}
+
+		lines = append(lines, s[start:i])
+		start = i + utf8.RuneLen(r)
+		if r == '\r' && strings.HasPrefix(s[start:], "\n") {
+			start++
+		}
+	}
+	return append(lines, s[start:])
+}
+
 func scanProp(cx *parseContext, sc *pkg.Scanner, name, typeAnnotation string) (Prop, error) {
 	_, _ = sc.ScanWhitespace()
 
@@ -488,6 +861,9 @@ func scanProp(cx *parseContext, sc *pkg.Scanner, name, typeAnnotation string) (P
 		case pkg.INVALID:
 			return Prop{}, fmt.Errorf("invalid property value")
 		case pkg.NUM_INT:
+			if err := checkNumberEnd(sc); err != nil {
+				return Prop{}, err
+			}
 			n, err := parseIntValue(lit, valueTypeAnnot)
 			if err != nil {
 				return Prop{}, err
@@ -495,14 +871,29 @@ func scanProp(cx *parseContext, sc *pkg.Scanner, name, typeAnnotation string) (P
 			value = n
 			done = true
 		case pkg.NUM_FLOAT, pkg.NUM_SCI:
+			if err := checkNumberEnd(sc); err != nil {
+				return Prop{}, err
+			}
 			n, err := parseFloatValue(lit, valueTypeAnnot)
 			if err != nil {
 				return Prop{}, err
 			}
 			value = n
 			done = true
-		case pkg.QUOTE:
-			s, err := scanString(cx, sc, valueTypeAnnot)
+		case pkg.QUOTE, pkg.RAWSTR_OPEN, pkg.RAWSTR_HASH_OPEN, pkg.RAWSTR_HASH_CLOSE:
+			var s string
+			var err error
+			switch token {
+			case pkg.QUOTE:
+				s, err = scanString(cx, sc, valueTypeAnnot)
+			case pkg.RAWSTR_HASH_CLOSE:
+				s, err = scanString(cx, sc, valueTypeAnnot)
+				s = lit[1:] + s
+			case pkg.RAWSTR_OPEN:
+				s, err = scanRawString(cx, sc, valueTypeAnnot)
+			case pkg.RAWSTR_HASH_OPEN:
+				s, err = scanRawStringHash(cx, sc, lit, valueTypeAnnot)
+			}
 			if err != nil {
 				return Prop{}, err
 			}
@@ -517,28 +908,20 @@ func scanProp(cx *parseContext, sc *pkg.Scanner, name, typeAnnotation string) (P
 			valueTypeAnnot = t
 		default:
 			// Not a number or string => try parse bool or null
-			sc.Unread()
-			t, letters := sc.ScanLetters()
-			if t != pkg.EOF {
-				switch letters {
-				case "null":
-					value = nil
-				case "true":
-					value = true
-				case "false":
-					value = false
-				default:
-					return Prop{}, fmt.Errorf("invalid property value")
-				}
-
-				if valueTypeAnnot != "" {
-					return Prop{}, fmt.Errorf("unexpected type annotation")
-				}
+			if !pkg.IsInitialIdentToken(token) {
+				return Prop{}, fmt.Errorf("invalid property value")
+			}
 
-				done = true
-			} else {
+			v, ok := keywordValue(lit + sc.ScanBareIdent())
+			if !ok {
 				return Prop{}, fmt.Errorf("invalid property value")
 			}
+			if valueTypeAnnot != "" {
+				return Prop{}, fmt.Errorf("unexpected type annotation")
+			}
+
+			value = v
+			done = true
 		}
 	}
 
@@ -550,6 +933,46 @@ func scanProp(cx *parseContext, sc *pkg.Scanner, name, typeAnnotation string) (P
 	}, nil
 }
 
+// keywordValue returns the value of the keyword id,
+// and false if id is not a keyword with a value.
+func keywordValue(id string) (any, bool) {
+	switch id {
+	case "null":
+		return nil, true
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return nil, false
+}
+
+// checkIdentifier returns an error if id is not a valid bare identifier.
+func checkIdentifier(id string) error {
+	if pkg.IsKeyword(id) {
+		return fmt.Errorf("keyword %s cannot be used as an identifier unless quoted", id)
+	} else if !pkg.IsBareIdent(id) {
+		return fmt.Errorf("invalid identifier: %s", id)
+	}
+	return nil
+}
+
+// checkNumberEnd returns an error if the number that was just
+// scanned is directly followed by an identifier rune, e.g. 1abc,
+// which is neither a valid number nor a valid identifier.
+func checkNumberEnd(sc *pkg.Scanner) error {
+	r := sc.ScanRune()
+	if r == pkg.EOF_RUNE {
+		return nil
+	}
+
+	sc.UnscanRune()
+	if pkg.IsIdentifier(r) {
+		return fmt.Errorf("invalid number: unexpected %q after number", r)
+	}
+	return nil
+}
+
 func scanTypeAnnotation(cx *parseContext, sc *pkg.Scanner) (string, error) {
 	annot := sc.ScanWhile(func(r rune) bool {
 		return unicode.In(r, unicode.Digit, unicode.Letter)
@@ -564,6 +987,9 @@ func scanTypeAnnotation(cx *parseContext, sc *pkg.Scanner) (string, error) {
 	if annot == "" {
 		return "", fmt.Errorf("invalid type annotation: empty")
 	}
+	if err := checkIdentifier(annot); err != nil {
+		return "", fmt.Errorf("invalid type annotation: %s", err)
+	}
 
 	return annot, nil
 }
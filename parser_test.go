@@ -2,7 +2,10 @@ package gokdl
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	// "os"
@@ -58,12 +61,67 @@ func TestParserNewlineEscaping(t *testing.T) {
 	}
 }
 
+func TestParserByteOrderMark(t *testing.T) {
+	// Arrange
+	src := "\uFEFFnode 1"
+
+	// Act
+	nodes := setupAndParse(t, src).Nodes()
+	_, err := setup("node\uFEFF").parse()
+
+	// Assert
+	require.Len(t, nodes, 1)
+	require.Equal(t, "node", nodes[0].Name)
+	require.Error(t, err, "only a leading byte order mark is allowed")
+}
+
+func TestParserInvalidCodePoints(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		pos      Position
+		message  string
+	}{
+		{"nul", "node\x00 other", Position{Offset: 4, Line: 1, Column: 5}, "disallowed code point U+0000"},
+		{"nul after node", "node\n\x00", Position{Offset: 5, Line: 2, Column: 1}, "disallowed code point U+0000"},
+		{"control character in string", "node \"a\x1bb\"", Position{Offset: 7, Line: 1, Column: 8}, "disallowed code point U+001B"},
+		{"delete in comment", "// \x7f\nnode", Position{Offset: 3, Line: 1, Column: 4}, "disallowed code point U+007F"},
+		{"direction override", "node \"\u202e\"", Position{Offset: 6, Line: 1, Column: 7}, "disallowed code point U+202E"},
+		{"byte order mark", "a\nb\uFEFF", Position{Offset: 3, Line: 2, Column: 2}, "disallowed code point U+FEFF"},
+		{"invalid utf-8", "node \"a\xffb\"", Position{Offset: 7, Line: 1, Column: 8}, "invalid UTF-8 encoding"},
+		{"surrogate", "node \xed\xa0\x80", Position{Offset: 5, Line: 1, Column: 6}, "invalid UTF-8 encoding"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			_, err := setup(test.body).parse()
+			_, strErr := newParserString(context.Background(), test.body).parse()
+
+			// Assert
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, test.pos, perr.Pos)
+			require.EqualError(t, perr.Err, test.message)
+			require.Equal(t, err, strErr)
+		})
+	}
+}
+
 func TestParserLineComment(t *testing.T) {
 	_ = setupAndParse(t, `// First line
 // Second line
 // Thirdline`)
 }
 
+func TestParserLineContinuationComment(t *testing.T) {
+	// Act
+	doc := setupAndParse(t, "node 1 \\ // the rest\n  2 3\nother")
+
+	// Assert
+	require.Equal(t, "node 1 2 3\nother\n", doc.String())
+}
+
 func TestParserMultilineComment(t *testing.T) {
 	tests := []struct {
 		testname string
@@ -71,6 +129,11 @@ func TestParserMultilineComment(t *testing.T) {
 	}{
 		{"single line", "/* comment */"},
 		{"single line - two comments", "/* comment */ /* another */"},
+		{"nested", "/* a /* b */ c */"},
+		{"nested twice", "/* /* /* a */ */ */"},
+		{"nested in node", "node /* a /* b */ c */ 1"},
+		{"slash and star", "/* / * */"},
+		{"comment end and start", "/* /*/ */ */"},
 		{
 			"multiple lines", `/*
 comment
@@ -86,6 +149,42 @@ another
 	}
 }
 
+func TestParserMultilineCommentNested(t *testing.T) {
+	// Act
+	doc := setupAndParse(t, "node /* a /* b */ c */ 1 /**/ 2")
+
+	// Assert
+	require.Equal(t, "node 1 2\n", doc.String())
+}
+
+func TestParserMultilineCommentUnterminated(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		pos      Position
+	}{
+		{"not closed", "node\n/* a", Position{Offset: 5, Line: 2, Column: 1}},
+		{"outer not closed", "/* a /* b */ c", Position{Offset: 0, Line: 1, Column: 1}},
+		{"outer not closed in node", "node /* a\n  /* b */", Position{Offset: 5, Line: 1, Column: 6}},
+		{"innermost not closed", "/* a /* b", Position{Offset: 5, Line: 1, Column: 6}},
+		{"in children", "node {\n  child /* a\n}", Position{Offset: 15, Line: 2, Column: 9}},
+		{"star slash star", "/*/", Position{Offset: 0, Line: 1, Column: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			_, err := setup(test.body).parse()
+
+			// Assert
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, test.pos, perr.Pos)
+			require.EqualError(t, perr.Err, "unterminated comment: missing */")
+		})
+	}
+}
+
 func TestParserSlashdashCommentNode(t *testing.T) {
 	doc := setupAndParse(t, `/-mynode`)
 	nodes := doc.Nodes()
@@ -139,6 +238,82 @@ func TestParserSlashdashCommentNestedChildren(t *testing.T) {
 	require.Len(t, nodes[0].Children, 1)
 }
 
+func TestParserSlashdash(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		expected string // The printed document
+	}{
+		{"quoted node", `/-"my node" 1` + "\nnode", "node\n"},
+		{"raw string node", `/-#"my node"# 1` + "\nnode", "node\n"},
+		{"type annotated node", "/-(t)skipped\nnode", "node\n"},
+		{"whitespace before node", "/- \t skipped\nnode", "node\n"},
+		{"newline before node", "/-\n  skipped 1\nnode", "node\n"},
+		{"comment before node", "/- /* comment */ skipped; node", "node\n"},
+		{"child node", "node {\n/- (t)\"child\" { a }\n}", "node\n"},
+		{"type annotated arg", "node /-(u8)1 2", "node 2\n"},
+		{"string arg before semicolon", `node /-"a";other`, "node\nother\n"},
+		{"keyword arg", "node /-true 1", "node 1\n"},
+		{"null arg", "node /-null null", "node null\n"},
+		{"whitespace before arg", `node /- "a" 1`, "node 1\n"},
+		{"newline before arg", "node /-\n  1 2", "node 2\n"},
+		{"line comment before arg", "node /- // comment\n  1 2", "node 2\n"},
+		{"quoted prop", `node /-"a b"=1 c=2`, "node c=2\n"},
+		{"type annotated prop", "node /-(t)a=(u8)1", "node\n"},
+		{"whitespace before children", "node /- {\n  child\n}", "node\n"},
+		{"children before children", "node /-{ a } { b }", "node {\n    b\n}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			doc := setupAndParse(t, test.body)
+
+			// Assert
+			require.Equal(t, test.expected, doc.String())
+		})
+	}
+}
+
+func TestParserSlashdashRetained(t *testing.T) {
+	// Act
+	doc, err := ParseString("/-skipped\nnode /-1 /-{ child }", RetainSlashDash())
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "node\n", doc.String())
+}
+
+func TestParserSlashdashInvalid(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+	}{
+		{"end of document", "/-"},
+		{"end of document after whitespace", "/- \n"},
+		{"before close of children", "node { /- }"},
+		{"before semicolon", "/-;node"},
+		{"twice", "/-/-node"},
+		{"after type annotation", "(t)/-node"},
+		{"arg at end of node", "node /-"},
+		{"arg before semicolon", "node /-; other"},
+		{"arg before close of children", "parent { node /-}"},
+		{"arg twice", "node /- /-1"},
+		{"arg after type annotation", "node (t)/-1"},
+		{"type annotation at end of node", "node (t)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			_, err := setup(test.body).parse()
+
+			// Assert
+			require.Error(t, err)
+		})
+	}
+}
+
 func TestParserValidNodeIdentifier(t *testing.T) {
 	tests := []struct {
 		testname     string
@@ -150,6 +325,18 @@ func TestParserValidNodeIdentifier(t *testing.T) {
 		{"end with number", "node_name123", "node_name123"},
 		{"arbitrary characters #1", "-this_actually::WORKS?", "-this_actually::WORKS?"},
 		{"quoted named", "\"Node Name?\"", "Node Name?"},
+		{"sign only", "-", "-"},
+		{"sign and letters", "+abc", "+abc"},
+		{"two signs", "--1", "--1"},
+		{"dot only", ".", "."},
+		{"dot and letters", ".abc", ".abc"},
+		{"sign and dot", "-.", "-."},
+		{"two dots and digit", "..5", "..5"},
+		{"keyword prefix", "trueish", "trueish"},
+		{"keyword and digit", "null1", "null1"},
+		{"keyword and sign", "false-", "false-"},
+		{"non-ascii digit", "٣x", "٣x"},
+		{"quoted keyword", "\"true\"", "true"},
 	}
 
 	for _, test := range tests {
@@ -174,6 +361,20 @@ func TestParserNodeIdentifierInvalid(t *testing.T) {
 		{"square brackets", "a[b]c"},
 		{"equal", "a=c"},
 		{"comma", "abcD,,Y"},
+		{"quote", `a"b"`},
+		{"leading digit", "1abc"},
+		{"sign and digit", "-1abc"},
+		{"dot and digit", ".5"},
+		{"sign, dot and digit", "+.5x"},
+		{"hash", "#name"},
+		{"hash inside", "a#b"},
+		{"true", "true"},
+		{"false", "false"},
+		{"null", "null"},
+		{"inf", "inf"},
+		{"negative inf", "-inf"},
+		{"nan", "nan"},
+		{"slash-dashed keyword", "/-true"},
 	}
 
 	for _, test := range tests {
@@ -185,6 +386,62 @@ func TestParserNodeIdentifierInvalid(t *testing.T) {
 	}
 }
 
+func TestParserKeywordsAndIdentifiers(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		args     []Arg
+		props    []Prop
+	}{
+		{"keywords", "node true false null", []Arg{{Value: true}, {Value: false}, {Value: nil}}, []Prop{}},
+		{"keyword prefix as property", "node true1=1", []Arg{}, []Prop{{Name: "true1", Value: int64(1)}}},
+		{"signed property", "node -a=1 +.b=2", []Arg{}, []Prop{{Name: "-a", Value: int64(1)}, {Name: "+.b", Value: int64(2)}}},
+		{"keyword property value", "node a=null", []Arg{}, []Prop{{Name: "a", Value: nil}}},
+		{"quoted keyword property", `node "true"=1`, []Arg{}, []Prop{{Name: "true", Value: int64(1)}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			nodes := setupAndParse(t, test.body).Nodes()
+
+			// Assert
+			require.Len(t, nodes, 1)
+			require.Equal(t, test.args, nodes[0].Args)
+			require.Equal(t, test.props, nodes[0].Props)
+		})
+	}
+}
+
+func TestParserKeywordsAndIdentifiersInvalid(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+	}{
+		{"keyword prefix as argument", "node true1"},
+		{"keyword prefix as property value", "node a=true1"},
+		{"keyword as property name", "node true=1"},
+		{"reserved keyword as property name", "node inf=1"},
+		{"number and letters", "node 1a=2"},
+		{"float and letters", "node 1.5x"},
+		{"number and letters as property value", "node a=1x"},
+		{"dot and digit as property name", "node .5=1"},
+		{"hash as property name", "node #a=1"},
+		{"keyword type annotation", "node (true)1"},
+		{"number type annotation", "node (8)1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			_, err := setup(test.body).parse()
+
+			// Assert
+			require.Error(t, err)
+		})
+	}
+}
+
 func TestParserNodeArgs(t *testing.T) {
 	// Arrange
 	nodeName := "node"
@@ -216,6 +473,14 @@ func TestParserNodeArgs(t *testing.T) {
 		{"false", "node false", false},
 		{"hex - small caps", "node 0x1aaeff", int64(1748735)},
 		{"hex - mixed caps", "node 0x1AAeff", int64(1748735)},
+		{"float followed by whitespace", "node 1.5 ", 1.5},
+		{"float followed by semicolon", "node 1.5;", 1.5},
+		{"scientific without fraction", "node 1e3", 1000.0},
+		{"scientific uppercase", "node 1.5E+2", 150.0},
+		{"string with numbers", `node "0x10 1_000 1.2.3"`, "0x10 1_000 1.2.3"},
+		{"string ending in r", `node "bar"`, "bar"},
+		{"rawstring with numbers", `node r"0x10"`, "0x10"},
+		{"rawstringhash with fewer hashes", `node r##"a"#b"##`, `a"#b`},
 	}
 
 	for _, test := range tests {
@@ -241,6 +506,14 @@ func TestParserNodeArgs(t *testing.T) {
 	}
 }
 
+func TestParserNodeArgsAdjacentStrings(t *testing.T) {
+	// Act
+	doc := setupAndParse(t, `node "a""\"" "b"r"c"`)
+
+	// Assert
+	require.Equal(t, []Arg{{Value: "a"}, {Value: `"`}, {Value: "b"}, {Value: "c"}}, doc.Nodes()[0].Args)
+}
+
 func TestParserNodeArgsInvalid(t *testing.T) {
 	// Arrange
 	tests := []struct {
@@ -340,6 +613,51 @@ func TestParserNodeProp(t *testing.T) {
 	}
 }
 
+func TestParserNodePropDuplicates(t *testing.T) {
+	// Act
+	doc := setupAndParse(t, `NodeName a=1 b=2 a=(u8)3 c=4 b="two"`)
+
+	// Assert
+	require.Equal(t, []Prop{
+		{Name: "a", Value: uint64(3), ValueTypeAnnot: U8},
+		{Name: "b", Value: "two"},
+		{Name: "c", Value: int64(4)},
+	}, doc.Nodes()[0].Props)
+}
+
+func TestParserNodePropDuplicatesMany(t *testing.T) {
+	// Arrange
+	var src strings.Builder
+	var expected []Prop
+	src.WriteString("node")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&src, " p%d=%d", i, i)
+		expected = append(expected, Prop{Name: fmt.Sprintf("p%d", i), Value: int64(i + 100)})
+	}
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&src, " p%d=%d", i, i+100)
+	}
+
+	// Act
+	doc := setupAndParse(t, src.String())
+
+	// Assert
+	require.Equal(t, expected, doc.Nodes()[0].Props)
+}
+
+func TestParserNodePropDuplicatesKept(t *testing.T) {
+	// Act
+	doc, err := ParseString(`NodeName a=1 b=2 a=3`, KeepDuplicateProps())
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, []Prop{
+		{Name: "a", Value: int64(1)},
+		{Name: "b", Value: int64(2)},
+		{Name: "a", Value: int64(3)},
+	}, doc.Nodes()[0].Props)
+}
+
 func TestParserNodePropInvalid(t *testing.T) {
 	// Arrange
 	tests := []struct {
@@ -409,6 +727,23 @@ func TestParserNodePropTypeAnnotation(t *testing.T) {
 	}
 }
 
+func TestParserNodePropTypeAnnotationNotCarriedOver(t *testing.T) {
+	// Arrange
+	parser := setup("NodeName (author)other=1 myprop=1")
+
+	// Act
+	doc, err := parser.parse()
+
+	// Assert
+	require.NoError(t, err)
+	props := doc.Nodes()[0].Props
+	require.Len(t, props, 2)
+	require.Equal(t, TypeAnnotation("author"), props[0].TypeAnnot)
+	require.Equal(t, "myprop", props[1].Name)
+	require.Equal(t, TypeAnnotation(noTypeAnnot), props[1].TypeAnnot)
+	require.Equal(t, TypeAnnotation(noTypeAnnot), props[1].ValueTypeAnnot)
+}
+
 func TestParserNodeTypeAnnotation(t *testing.T) {
 	// Arrange
 	nodeName := "NodeName"
@@ -519,6 +854,18 @@ func TestParserNodeChildren(t *testing.T) {
 	require.Len(t, children, 3)
 }
 
+func TestParserNodeChildrenSiblingAfterBlock(t *testing.T) {
+	doc := setupAndParse(t, "Parent { child }\nSibling")
+	require.Len(t, doc.nodes, 2)
+	require.Len(t, doc.nodes[0].Children, 1)
+	require.Equal(t, "Sibling", doc.nodes[1].Name)
+}
+
+func TestParserNodeChildrenUnclosed(t *testing.T) {
+	_, err := setup("Parent {\n\tchild").parse()
+	require.Error(t, err)
+}
+
 func TestParserNodeChildrenSingle(t *testing.T) {
 	doc := setupAndParse(t, `Parent {
 	child
@@ -562,6 +909,187 @@ func TestParserStringsEscaped(t *testing.T) {
 	require.Equal(t, `"`, nodes[2].Args[0].Value)
 }
 
+func TestParserStringEscapes(t *testing.T) {
+	tests := []struct {
+		testname string
+		str      string
+		expected string
+	}{
+		{"newline", `"a\nb"`, "a\nb"},
+		{"carriage return", `"\r"`, "\r"},
+		{"tab", `"\t"`, "\t"},
+		{"backslash", `"\\"`, "\\"},
+		{"slash", `"\/"`, "/"},
+		{"quote", `"\""`, `"`},
+		{"backspace", `"\b"`, "\b"},
+		{"form feed", `"\f"`, "\f"},
+		{"space", `"a\sb"`, "a b"},
+		{"unicode", `"\u{E9}"`, "é"},
+		{"unicode single digit", `"\u{9}"`, "\t"},
+		{"unicode emoji", `"\u{1F600}"`, "\U0001F600"},
+		{"unicode max", `"\u{10FFFF}"`, "\U0010FFFF"},
+		{"unicode leading zeros", `"\u{00000A}"`, "\n"},
+		{"whitespace escape", `"a\    b"`, "ab"},
+		{"whitespace escape newlines", "\"a\\\n\t\n  b\"", "ab"},
+		{"whitespace escape before escape", "\"a\\\n  \\nb\"", "a\nb"},
+		{"literal newline", "\"a\nb\"", "a\nb"},
+		{"mixed", `"x\ty\u{41}\"z"`, "x\tyA\"z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			for name, parse := range map[string]func(string) (Doc, error){
+				"reader": func(s string) (Doc, error) { return Parse(strings.NewReader(s)) },
+				"string": func(s string) (Doc, error) { return ParseString(s) },
+			} {
+				doc, err := parse("node " + test.str)
+				require.NoError(t, err, name)
+				require.Equal(t, test.expected, doc.Nodes()[0].Args[0].Value, name)
+			}
+		})
+	}
+}
+
+func TestParserStringEscapesInvalid(t *testing.T) {
+	tests := []struct {
+		testname string
+		str      string
+		err      string
+	}{
+		{"go hex", `"\x41"`, `invalid escape sequence in string: \x`},
+		{"go bell", `"\a"`, `invalid escape sequence in string: \a`},
+		{"go vertical tab", `"\v"`, `invalid escape sequence in string: \v`},
+		{"go octal", `"\101"`, `invalid escape sequence in string: \1`},
+		{"go unicode", `"\u00E9"`, `expected \u{X}`},
+		{"go long unicode", `"\U0001F600"`, `invalid escape sequence in string: \U`},
+		{"single quote", `"\'"`, `invalid escape sequence in string: \'`},
+		{"unicode empty", `"\u{}"`, `must have 1 to 6 hex digits`},
+		{"unicode too long", `"\u{0000041}"`, `must have 1 to 6 hex digits`},
+		{"unicode not hex", `"\u{4G}"`, `expected hex digit or }`},
+		{"unicode unterminated", `"\u{41"`, `expected hex digit or }`},
+		{"unicode surrogate", `"\u{D800}"`, `\u{D800} is not a Unicode scalar value`},
+		{"unicode too large", `"\u{110000}"`, `\u{110000} is not a Unicode scalar value`},
+		{"eof", `"\`, `reached EOF`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			_, err := ParseString("node " + test.str)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestParserMultilineStrings(t *testing.T) {
+	tests := []struct {
+		testname string
+		str      string
+		expected string
+	}{
+		{"single line", "\"\"\"\n  hello\n  \"\"\"", "hello"},
+		{"dedent", "\"\"\"\n    SELECT *\n      FROM t\n    WHERE x\n    \"\"\"", "SELECT *\n  FROM t\nWHERE x"},
+		{"no indentation", "\"\"\"\na\nb\n\"\"\"", "a\nb"},
+		{"empty", "\"\"\"\n\"\"\"", ""},
+		{"empty line", "\"\"\"\n\n  \"\"\"", ""},
+		{"whitespace only lines", "\"\"\"\n  a\n     \n\n  b\n  \"\"\"", "a\n\n\nb"},
+		{"trailing newline", "\"\"\"\n  a\n\n  \"\"\"", "a\n"},
+		{"crlf", "\"\"\"\r\n  a\r\n  b\r\n  \"\"\"", "a\nb"},
+		{"other newlines", "\"\"\"\n a\u2028 b\r b\n \"\"\"", "a\nb\nb"},
+		{"escapes", "\"\"\"\n  a\\tb\\u{41}\n  \"\"\"", "a\tbA"},
+		{"escaped newline", "\"\"\"\n  a\\n\n  \"\"\"", "a\n"},
+		{"whitespace escape", "\"\"\"\n  a \\\n  b\n  \"\"\"", "a b"},
+		{"quotes", "\"\"\"\n  \"a\" \"\"b\"\"\n  \"\"\"", `"a" ""b""`},
+		{"escaped quotes", "\"\"\"\n  \\\"\"\"\n  \"\"\"", `"""`},
+		{"raw", "#\"\"\"\n  a\\nb \"\"\"\n  \"\"\"#", `a\nb """`},
+		{"raw two hashes", "##\"\"\"\n  \"\"\"#\n  \"\"\"##", `"""#`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			for name, parse := range map[string]func(string) (Doc, error){
+				"reader": func(s string) (Doc, error) { return Parse(strings.NewReader(s)) },
+				"string": func(s string) (Doc, error) { return ParseString(s) },
+			} {
+				doc, err := parse("node " + test.str + " key=1")
+				require.NoError(t, err, name)
+				node := doc.Nodes()[0]
+				require.Equal(t, test.expected, node.Args[0].Value, name)
+				require.Equal(t, []Prop{{Name: "key", Value: int64(1)}}, node.Props, name)
+
+				doc, err = parse("node key=" + test.str)
+				require.NoError(t, err, name)
+				require.Equal(t, test.expected, doc.Nodes()[0].Props[0].Value, name)
+			}
+		})
+	}
+}
+
+func TestParserMultilineStringsInvalid(t *testing.T) {
+	tests := []struct {
+		testname string
+		str      string
+		err      string
+	}{
+		{"no newline", "\"\"\"a\n\"\"\"", `expected a newline after """`},
+		{"closing not on own line", "\"\"\"\n  a\n  b\"\"\"", `must be on its own line`},
+		{"indentation", "\"\"\"\n  a\n b\n  \"\"\"", `line 2 is not indented like the closing """`},
+		{"mixed indentation", "\"\"\"\n\ta\n  \"\"\"", `line 1 is not indented`},
+		{"unterminated", "\"\"\"\n  a\n", `reached EOF`},
+		{"unterminated raw", "#\"\"\"\n  a\n  \"\"\"", `reached EOF`},
+		{"invalid escape", "\"\"\"\n  \\x\n  \"\"\"", `invalid escape sequence`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			_, err := ParseString("node " + test.str)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestParserRawStringsHash(t *testing.T) {
+	doc := setupAndParse(t, "node #\"a \"quoted\" \\n\"# ##\"b\"#\"##")
+	require.Equal(t, []Arg{{Value: `a "quoted" \n`}, {Value: `b"#`}}, doc.Nodes()[0].Args)
+}
+
+func TestParserRawStringProps(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		expected string
+	}{
+		{"raw", `node key=r"a\n"`, `a\n`},
+		{"hash", `node key=#"a "quoted" \n"#`, `a "quoted" \n`},
+		{"two hashes", `node key=##"b"#"##`, `b"#`},
+		{"type annotation", `node key=(t)#"a"#`, "a"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Act
+			doc := setupAndParse(t, test.body)
+
+			// Assert
+			props := doc.Nodes()[0].Props
+			require.Len(t, props, 1)
+			require.Equal(t, "key", props[0].Name)
+			require.Equal(t, test.expected, props[0].Value)
+		})
+	}
+}
+
+// addCorpus adds the KDL documents in testdata as seeds to the fuzz target.
+func addCorpus(f *testing.F) {
+	filenames, err := filepath.Glob("testdata/*.kdl")
+	require.NoError(f, err)
+
+	for _, filename := range filenames {
+		bs, err := os.ReadFile(filename)
+		require.NoError(f, err)
+		f.Add(string(bs))
+	}
+}
+
 func setup(doc string) *parser {
 	r := strings.NewReader(doc)
 	return newParser(r)
@@ -595,3 +1123,29 @@ func totalChildren(doc Doc) int {
 	}
 	return total
 }
+
+func FuzzParse(f *testing.F) {
+	addCorpus(f)
+
+	f.Fuzz(func(t *testing.T, src string) {
+		doc, err := setup(src).parse()
+
+		// Property: parsing from a string and a reader gives the same result.
+		strDoc, strErr := newParserString(context.Background(), src).parse()
+		require.Equal(t, err, strErr)
+		require.Equal(t, doc, strDoc)
+
+		if err != nil {
+			return
+		}
+
+		// Property: printing and parsing a document results in an equal document
+		printed := doc.String()
+
+		reparsed, err := setup(printed).parse()
+		if err != nil {
+			t.Fatalf("failed to parse printed document: %s\n%s", err, printed)
+		}
+		require.Equal(t, doc, reparsed, "printed document:\n%s", printed)
+	})
+}
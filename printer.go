@@ -0,0 +1,243 @@
+package gokdl
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	pkg "github.com/lunjon/gokdl/internal"
+)
+
+const indentation = "    "
+
+// String returns the document formatted as KDL.
+func (d Doc) String() string {
+	var b strings.Builder
+	_, _ = d.WriteTo(&b)
+	return b.String()
+}
+
+// WriteTo writes the document formatted as KDL to w.
+// Parsing the output results in a document equal to d.
+func (d Doc) WriteTo(w io.Writer) (int64, error) {
+	p := &printer{w: w}
+	for _, n := range d.nodes {
+		p.printNode(n, 0)
+	}
+	return p.n, p.err
+}
+
+// printer writes nodes to a writer and keeps track of
+// the number of bytes written and the first error.
+type printer struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *printer) print(s string) {
+	if p.err != nil {
+		return
+	}
+	n, err := io.WriteString(p.w, s)
+	p.n += int64(n)
+	p.err = err
+}
+
+func (p *printer) printNode(n Node, depth int) {
+	p.print(strings.Repeat(indentation, depth))
+	p.printTypeAnnot(n.TypeAnnotation)
+	p.print(formatIdent(n.Name))
+
+	for _, arg := range n.Args {
+		p.print(" ")
+		p.printTypeAnnot(arg.TypeAnnotation)
+		p.printValue(arg.Value, depth)
+	}
+
+	for _, prop := range n.Props {
+		p.print(" ")
+		p.printTypeAnnot(prop.TypeAnnot)
+		p.print(formatIdent(prop.Name))
+		p.print("=")
+		p.printTypeAnnot(prop.ValueTypeAnnot)
+		p.printValue(prop.Value, depth)
+	}
+
+	if len(n.Children) > 0 {
+		p.print(" {\n")
+		for _, child := range n.Children {
+			p.printNode(child, depth+1)
+		}
+		p.print(strings.Repeat(indentation, depth))
+		p.print("}")
+	}
+
+	p.print("\n")
+}
+
+func (p *printer) printTypeAnnot(t TypeAnnotation) {
+	if t != noTypeAnnot {
+		p.print("(" + t.String() + ")")
+	}
+}
+
+// printValue prints the value of an argument or property of
+// a node at depth. Strings with many lines are printed as
+// multi-line strings, indented one level more than the node.
+func (p *printer) printValue(v any, depth int) {
+	if s, ok := v.(string); ok && strings.Count(s, "\n")+1 >= multilineMinLines {
+		p.print(quoteMultiline(s, strings.Repeat(indentation, depth+1)))
+		return
+	}
+	p.print(formatValue(v))
+}
+
+// formatIdent returns the identifier as is if it
+// can be written as a bare identifier, otherwise quoted.
+func formatIdent(s string) string {
+	if pkg.IsBareIdent(s) {
+		return s
+	}
+	return quoteString(s)
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return quoteString(v)
+	case int:
+		return strconv.FormatInt(int64(v), 10)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	default:
+		return quoteString(fmt.Sprint(v))
+	}
+}
+
+func formatFloat(f float64, bitsize int) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		// Not representable in KDL
+		return quoteString(strconv.FormatFloat(f, 'f', -1, bitsize))
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, bitsize)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+var quoteEscapes = map[rune]string{
+	'"':  `\"`,
+	'\\': `\\`,
+	'\n': `\n`,
+	'\r': `\r`,
+	'\t': `\t`,
+	'\b': `\b`,
+	'\f': `\f`,
+}
+
+// quoteString returns s as a quoted KDL string.
+// Control characters without a short escape, e.g. \n, and
+// disallowed code points are written as unicode escapes, e.g. \u{7F}.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteRune('"')
+	for _, r := range s {
+		if esc, ok := quoteEscapes[r]; ok {
+			b.WriteString(esc)
+		} else if unicode.IsControl(r) || pkg.IsDisallowed(r) {
+			fmt.Fprintf(&b, "\\u{%X}", r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune('"')
+	return b.String()
+}
+
+// Strings with at least this many lines are printed as multi-line strings.
+const multilineMinLines = 3
+
+// quoteMultiline returns s as a multi-line KDL string with
+// each line, and the closing quotes, prefixed by indent.
+func quoteMultiline(s, indent string) string {
+	var b strings.Builder
+	b.WriteString("\"\"\"\n")
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			b.WriteString(indent)
+			writeMultilineLine(&b, line)
+		}
+		b.WriteRune('\n')
+	}
+	b.WriteString(indent)
+	b.WriteString(`"""`)
+	return b.String()
+}
+
+func writeMultilineLine(b *strings.Builder, line string) {
+	if strings.TrimFunc(line, unicode.IsSpace) == "" {
+		// Whitespace-only lines are emptied when parsed, so the whitespace is escaped
+		for _, r := range line {
+			switch r {
+			case ' ':
+				b.WriteString(`\s`)
+			case '\t':
+				b.WriteString(`\t`)
+			default:
+				fmt.Fprintf(b, "\\u{%X}", r)
+			}
+		}
+		return
+	}
+
+	runes := []rune(line)
+	for i, r := range runes {
+		switch {
+		case r == '"':
+			// Only quotes that would close the string are escaped
+			if i+2 < len(runes) && runes[i+1] == '"' && runes[i+2] == '"' {
+				b.WriteString(`\"`)
+			} else {
+				b.WriteRune(r)
+			}
+		case r == '\t':
+			b.WriteRune(r)
+		case quoteEscapes[r] != "":
+			b.WriteString(quoteEscapes[r])
+		case unicode.IsControl(r) || pkg.IsDisallowed(r) || pkg.IsNewline(r):
+			fmt.Fprintf(b, "\\u{%X}", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package gokdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrinterNode(t *testing.T) {
+	tests := []struct {
+		testname string
+		node     Node
+		expected string
+	}{
+		{"name only", Node{Name: "node"}, "node\n"},
+		{"quoted name", Node{Name: "my node"}, "\"my node\"\n"},
+		{"keyword name", Node{Name: "true"}, "\"true\"\n"},
+		{"number name", Node{Name: "-1"}, "\"-1\"\n"},
+		{"reserved keyword name", Node{Name: "-inf"}, "\"-inf\"\n"},
+		{"dotted number name", Node{Name: ".5"}, "\".5\"\n"},
+		{"hash name", Node{Name: "#a"}, "\"#a\"\n"},
+		{"keyword prefix name", Node{Name: "true1"}, "true1\n"},
+		{"signed name", Node{Name: "-.a"}, "-.a\n"},
+		{"type annotation", Node{Name: "node", TypeAnnotation: "user"}, "(user)node\n"},
+		{
+			"args",
+			Node{Name: "node", Args: []Arg{
+				{Value: int64(1)},
+				{Value: 1.0},
+				{Value: "str"},
+				{Value: nil},
+				{Value: true},
+				{Value: uint64(255), TypeAnnotation: U8},
+			}},
+			"node 1 1.0 \"str\" null true (u8)255\n",
+		},
+		{
+			"props",
+			Node{Name: "node", Props: []Prop{
+				{Name: "a", Value: int64(1)},
+				{Name: "b c", Value: "d\n"},
+				{Name: "e", Value: 1.5, TypeAnnot: "author", ValueTypeAnnot: F64},
+			}},
+			"node a=1 \"b c\"=\"d\\n\" (author)e=(f64)1.5\n",
+		},
+		{
+			"control characters",
+			Node{Name: "node", Args: []Arg{{Value: "a\x00b\x1b\u007f\t"}}},
+			"node \"a\\u{0}b\\u{1B}\\u{7F}\\t\"\n",
+		},
+		{
+			"disallowed code points",
+			Node{Name: "a\u202eb", Args: []Arg{{Value: "\u200e\uFEFF"}}},
+			"\"a\\u{202E}b\" \"\\u{200E}\\u{FEFF}\"\n",
+		},
+		{
+			"short multi-line string",
+			Node{Name: "node", Args: []Arg{{Value: "a\nb"}}},
+			"node \"a\\nb\"\n",
+		},
+		{
+			"multi-line string",
+			Node{Name: "node", Props: []Prop{{Name: "sql", Value: "SELECT *\n  FROM t\n\nWHERE a = \"\"\"\"\n"}}},
+			"node sql=\"\"\"\n    SELECT *\n      FROM t\n\n    WHERE a = \\\"\\\"\"\"\n\n    \"\"\"\n",
+		},
+		{
+			"multi-line string with whitespace lines",
+			Node{Name: "node", Args: []Arg{{Value: "a\n \t\nb\rc\u2028"}}},
+			"node \"\"\"\n    a\n    \\s\\t\n    b\\rc\\u{2028}\n    \"\"\"\n",
+		},
+		{
+			"multi-line string in child",
+			Node{Name: "parent", Children: []Node{{Name: "child", Args: []Arg{{Value: "a\nb\nc"}}}}},
+			"parent {\n    child \"\"\"\n        a\n        b\n        c\n        \"\"\"\n}\n",
+		},
+		{
+			"children",
+			Node{Name: "parent", Children: []Node{
+				{Name: "child", Children: []Node{{Name: "grandchild"}}},
+			}},
+			"parent {\n    child {\n        grandchild\n    }\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			doc := Doc{nodes: []Node{test.node}}
+			require.Equal(t, test.expected, doc.String())
+		})
+	}
+}
+
+func TestPrinterRoundTripMultiline(t *testing.T) {
+	values := []string{
+		"a\nb\nc",
+		"\n\n",
+		"  indented\n\tand tabs\t\nend  ",
+		"quotes \"\"\"\n\"\"\"\"\"\nx\"",
+		"escapes \\n \\u{41}\n\\\n\\",
+		"  \n\t\n \u3000",
+		"crlf\r\nlines\r\n",
+	}
+
+	for _, value := range values {
+		doc := Doc{nodes: []Node{{Name: "node", Children: []Node{{
+			Name:     "child",
+			Children: []Node{},
+			Props:    []Prop{},
+			Args:     []Arg{{Value: value}},
+		}}, Props: []Prop{}, Args: []Arg{}}}}
+
+		printed := doc.String()
+		reparsed := setupAndParse(t, printed)
+		require.Equal(t, doc, reparsed, "printed document:\n%s", printed)
+	}
+}
+
+func TestPrinterRoundTrip(t *testing.T) {
+	doc := setupAndParse(t, `(author)node "arg" 1 2.5 (u8)3 prop="value" {
+	child r#"raw "string""# null true false
+	"quoted child" "quoted prop"=-1.5e-3
+}`)
+
+	reparsed := setupAndParse(t, doc.String())
+	require.Equal(t, doc, reparsed)
+}
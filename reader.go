@@ -0,0 +1,182 @@
+package gokdl
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var errReaderClosed = errors.New("reader is closed")
+
+// The maximum number of events sent at once from the parsing goroutine.
+const readerBatchSize = 256
+
+// Reader reads a document as a stream of events,
+// without building the nodes of the document.
+// The memory used is thus independent of the size
+// of the document, which makes it suitable for
+// very large documents.
+//
+// The document is parsed in a separate goroutine
+// as events are requested, so Close must be called
+// if Next is not called until it returns an error.
+//
+// Every occurrence of a property is read, even though
+// only the rightmost one applies according to the spec.
+type Reader struct {
+	parser *parser
+	cancel context.CancelFunc
+	// Position of the last event.
+	pos Position
+	// Events are sent in batches on the channel by the parsing
+	// goroutine, which sets err before closing it. Batches that
+	// have been read are returned on free to be reused.
+	events chan []posEvent
+	free   chan []posEvent
+	batch  []posEvent
+	err    error
+	closed bool
+}
+
+type posEvent struct {
+	ev  Event
+	pos Position
+}
+
+// NewReader returns a reader of the document in r.
+func NewReader(r io.Reader, opts ...Option) *Reader {
+	return NewReaderContext(context.Background(), r, opts...)
+}
+
+// NewReaderContext returns a reader of the document in r
+// that stops reading once the context is done.
+func NewReaderContext(ctx context.Context, r io.Reader, opts ...Option) *Reader {
+	ctx, cancel := context.WithCancel(ctx)
+	parser := newParserContext(ctx, r)
+	parser.opts = newOptions(opts)
+	return &Reader{
+		parser: parser,
+		cancel: cancel,
+	}
+}
+
+// Next returns the next event of the document.
+// It returns io.EOF when the whole document has been read,
+// or a *ParseError if the document is invalid.
+func (r *Reader) Next() (Event, error) {
+	if r.closed {
+		return nil, errReaderClosed
+	}
+
+	if r.events == nil {
+		r.start()
+	}
+
+	if len(r.batch) == 0 {
+		batch, ok := <-r.events
+		if !ok {
+			r.cancel()
+			if r.err != nil {
+				return nil, r.err
+			}
+			return nil, io.EOF
+		}
+		r.batch = batch
+	}
+
+	e := r.batch[0]
+	r.batch = r.batch[1:]
+	if len(r.batch) == 0 {
+		select {
+		case r.free <- r.batch[:0]:
+		default:
+		}
+	}
+
+	r.pos = e.pos
+	return e.ev, nil
+}
+
+// Pos returns the position of the event last returned by Next.
+func (r *Reader) Pos() Position {
+	return r.pos
+}
+
+// Close stops reading the document.
+// Any subsequent call to Next returns an error.
+func (r *Reader) Close() error {
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	r.cancel()
+	if r.events != nil {
+		// Wait for the parsing goroutine to stop
+		for range r.events {
+		}
+	}
+	return nil
+}
+
+func (r *Reader) start() {
+	r.events = make(chan []posEvent)
+	r.free = make(chan []posEvent, 1)
+	h := &chanHandler{
+		events: r.events,
+		free:   r.free,
+		done:   r.parser.ctx.Done(),
+	}
+
+	go func() {
+		defer close(r.events)
+		r.err = r.parser.run(h)
+		h.flush()
+	}()
+}
+
+// chanHandler is a handler that sends the events in batches on a channel.
+// A batch is sent when it is full or when a node in the root scope ends,
+// so that events are not held back longer than necessary.
+type chanHandler struct {
+	events chan<- []posEvent
+	free   <-chan []posEvent
+	done   <-chan struct{}
+	batch  []posEvent
+	depth  int
+}
+
+func (h *chanHandler) handle(ev Event, pos Position) {
+	if h.batch == nil {
+		select {
+		case h.batch = <-h.free:
+		default:
+			h.batch = make([]posEvent, 0, readerBatchSize)
+		}
+	}
+	h.batch = append(h.batch, posEvent{ev: ev, pos: pos})
+
+	switch ev.(type) {
+	case StartNode:
+		h.depth++
+	case EndNode:
+		h.depth--
+	}
+
+	if h.depth == 0 || len(h.batch) == readerBatchSize {
+		h.flush()
+	}
+}
+
+func (h *chanHandler) flush() {
+	if len(h.batch) == 0 {
+		return
+	}
+
+	select {
+	case h.events <- h.batch:
+	case <-h.done:
+		// The parser stops once it checks the context
+	}
+	h.batch = nil
+}
@@ -0,0 +1,256 @@
+package gokdl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderEvents(t *testing.T) {
+	// Arrange
+	r := NewReader(strings.NewReader(`// Comment
+(author)node "arg" /-"skipped" prop=(u8)1 {
+	child /* inline */ true
+}
+other; /-skipped { child }`))
+	defer r.Close()
+
+	// Act
+	events := readAll(t, r)
+
+	// Assert
+	require.Equal(t, []Event{
+		Comment{Text: "// Comment"},
+		StartNode{Name: "node", TypeAnnotation: "author"},
+		Arg{Value: "arg"},
+		Prop{Name: "prop", Value: uint64(1), ValueTypeAnnot: U8},
+		StartChildren{},
+		StartNode{Name: "child"},
+		Comment{Text: "/* inline */"},
+		Arg{Value: true},
+		EndNode{},
+		EndChildren{},
+		EndNode{},
+		StartNode{Name: "other"},
+		EndNode{},
+	}, events)
+}
+
+func TestReaderRetainSlashDash(t *testing.T) {
+	// Arrange
+	r := NewReader(strings.NewReader(`/- (t)skipped 1
+node /-"arg" /- key=2 /-{
+	child
+} { /-child }`), RetainSlashDash())
+	defer r.Close()
+
+	// Act
+	events := readAll(t, r)
+
+	// Assert
+	require.Equal(t, []Event{
+		SlashDash{},
+		StartNode{Name: "skipped", TypeAnnotation: "t"},
+		Arg{Value: int64(1)},
+		EndNode{},
+		StartNode{Name: "node"},
+		SlashDash{},
+		Arg{Value: "arg"},
+		SlashDash{},
+		Prop{Name: "key", Value: int64(2)},
+		SlashDash{},
+		StartChildren{},
+		StartNode{Name: "child"},
+		EndNode{},
+		EndChildren{},
+		StartChildren{},
+		SlashDash{},
+		StartNode{Name: "child"},
+		EndNode{},
+		EndChildren{},
+		EndNode{},
+	}, events)
+}
+
+func TestReaderRetainSlashDashPos(t *testing.T) {
+	r := NewReader(strings.NewReader("node /- 1"), RetainSlashDash())
+	defer r.Close()
+
+	expected := []Position{
+		{Offset: 0, Line: 1, Column: 1},  // node
+		{Offset: 5, Line: 1, Column: 6},  // /-
+		{Offset: 8, Line: 1, Column: 9},  // 1
+		{Offset: 9, Line: 1, Column: 10}, // end of node
+	}
+	for _, pos := range expected {
+		_, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, pos, r.Pos())
+	}
+}
+
+func TestReaderPos(t *testing.T) {
+	r := NewReader(strings.NewReader("node 1 {\n    (t)child key=\"value\"\n}"))
+	defer r.Close()
+
+	expected := []Position{
+		{Offset: 0, Line: 1, Column: 1},   // node
+		{Offset: 5, Line: 1, Column: 6},   // 1
+		{Offset: 7, Line: 1, Column: 8},   // {
+		{Offset: 13, Line: 2, Column: 5},  // (t)child
+		{Offset: 22, Line: 2, Column: 14}, // key="value"
+		{Offset: 34, Line: 3, Column: 1},  // end of child
+		{Offset: 34, Line: 3, Column: 1},  // }
+		{Offset: 35, Line: 3, Column: 2},  // end of node
+	}
+
+	for _, pos := range expected {
+		_, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, pos, r.Pos())
+	}
+
+	_, err := r.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestReaderError(t *testing.T) {
+	r := NewReader(strings.NewReader(`node (u8)"string"`))
+	defer r.Close()
+
+	ev, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, StartNode{Name: "node"}, ev)
+
+	_, err = r.Next()
+	var perr *ParseError
+	require.ErrorAs(t, err, &perr)
+
+	// The error is returned until the reader is closed
+	_, err = r.Next()
+	require.ErrorAs(t, err, &perr)
+}
+
+func TestReaderClose(t *testing.T) {
+	r := NewReader(strings.NewReader("a; b; c"))
+
+	_, err := r.Next()
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = r.Next()
+	require.Error(t, err)
+	require.False(t, errors.Is(err, io.EOF))
+}
+
+func TestReaderContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewReaderContext(ctx, strings.NewReader("a; b; c"))
+	defer r.Close()
+
+	_, err := r.Next()
+	require.NoError(t, err)
+	cancel()
+
+	// Events might already have been sent
+	for err == nil {
+		_, err = r.Next()
+	}
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReaderMatchesParse(t *testing.T) {
+	filenames, err := filepath.Glob("testdata/*.kdl")
+	require.NoError(t, err)
+
+	for _, filename := range filenames {
+		t.Run(filepath.Base(filename), func(t *testing.T) {
+			bs, err := os.ReadFile(filename)
+			require.NoError(t, err)
+
+			expected := setupAndParse(t, string(bs))
+
+			r := NewReader(strings.NewReader(string(bs)))
+			defer r.Close()
+
+			builder := &treeBuilder{nodes: []Node{}}
+			for _, ev := range readAll(t, r) {
+				builder.handle(ev, Position{})
+			}
+			require.Equal(t, expected, Doc{nodes: builder.nodes})
+		})
+	}
+}
+
+func TestReaderConstantMemory(t *testing.T) {
+	// Read a document of about 10 MB and check that the memory used
+	// while reading the end of it is the same as while reading the start.
+	node := "node 1 2.5 \"some string argument\" key=\"value\" { child; other-child true }\n"
+	src := &repeatReader{s: node, n: 10 << 20 / len(node)}
+
+	r := NewReader(src)
+	defer r.Close()
+
+	heap := func() uint64 {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		return stats.HeapInuse
+	}
+
+	var before uint64
+	for i := 0; ; i++ {
+		_, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if i == 1000 {
+			before = heap()
+		}
+	}
+
+	after := heap()
+	require.Less(t, after, before+1<<20, "heap in use grew from %d to %d bytes", before, after)
+}
+
+// repeatReader reads s repeated n times.
+type repeatReader struct {
+	s   string
+	n   int
+	buf string
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.buf == "" {
+		if r.n == 0 {
+			return 0, io.EOF
+		}
+		r.buf = r.s
+		r.n--
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func readAll(t *testing.T, r *Reader) []Event {
+	var events []Event
+	for {
+		ev, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, ev)
+	}
+}
@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"math"
+
+	"github.com/lunjon/gokdl"
+)
+
+// Doc returns the schema as a KDL Schema document, e.g. to
+// review and commit a schema returned by Infer. Parsing the
+// document with Parse results in a schema equal to s.
+func (s *Schema) Doc() gokdl.Doc {
+	return gokdl.NewDoc([]gokdl.Node{
+		newNode("document", nil, nodeDefs(s.Nodes)...),
+	})
+}
+
+// String returns the schema as a KDL Schema document.
+func (s *Schema) String() string {
+	return s.Doc().String()
+}
+
+func nodeDefs(nodes []*Node) []gokdl.Node {
+	defs := []gokdl.Node{}
+	for _, n := range nodes {
+		var children []gokdl.Node
+		if n.Min > 0 {
+			children = append(children, newNode("min", int64(n.Min)))
+		}
+		if n.Max > 0 {
+			children = append(children, newNode("max", int64(n.Max)))
+		}
+
+		for _, a := range n.Args {
+			var counts []gokdl.Node
+			if a.Optional {
+				counts = append(counts, newNode("min", int64(0)))
+			}
+			if a.Repeated {
+				counts = append(counts, newNode("repeated", true))
+			}
+			value := newNode("value", nil, append(counts, valueDefs(a.Value)...)...)
+			children = append(children, describe(value, a.Description))
+		}
+
+		for _, p := range n.Props {
+			var required []gokdl.Node
+			if p.Required {
+				required = append(required, newNode("required", true))
+			}
+			prop := newNode("prop", p.Name, append(required, valueDefs(p.Value)...)...)
+			children = append(children, describe(prop, p.Description))
+		}
+
+		if len(n.Children) > 0 {
+			children = append(children, newNode("children", nil, nodeDefs(n.Children)...))
+		}
+		defs = append(defs, describe(newNode("node", n.Name, children...), n.Description))
+	}
+	return defs
+}
+
+// valueDefs returns the nodes of the validations of v.
+func valueDefs(v Value) []gokdl.Node {
+	var nodes []gokdl.Node
+	if v.Type != "" {
+		nodes = append(nodes, newNode("type", v.Type))
+	}
+	if v.Format != "" {
+		nodes = append(nodes, newNode("format", v.Format))
+	}
+	if v.Enum != nil {
+		enum := newNode("enum", nil)
+		for _, e := range v.Enum {
+			enum.Args = append(enum.Args, gokdl.Arg{Value: e})
+		}
+		nodes = append(nodes, enum)
+	}
+	if v.Pattern != "" {
+		nodes = append(nodes, newNode("pattern", v.Pattern))
+	}
+	if v.Min != nil {
+		nodes = append(nodes, newNode(">=", bound(*v.Min)))
+	}
+	if v.Max != nil {
+		nodes = append(nodes, newNode("<=", bound(*v.Max)))
+	}
+	return nodes
+}
+
+// bound returns the bound n as an integer if it is one.
+func bound(n float64) any {
+	if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+		return int64(n)
+	}
+	return n
+}
+
+// newNode returns a node with the name, the argument
+// arg unless it is nil, and the children.
+func newNode(name string, arg any, children ...gokdl.Node) gokdl.Node {
+	n := gokdl.Node{Name: name, Args: []gokdl.Arg{}, Props: []gokdl.Prop{}, Children: []gokdl.Node{}}
+	if arg != nil {
+		n.Args = append(n.Args, gokdl.Arg{Value: arg})
+	}
+	n.Children = append(n.Children, children...)
+	return n
+}
+
+// describe returns n with the description as a property, if any.
+func describe(n gokdl.Node, description string) gokdl.Node {
+	if description != "" {
+		n.Props = append(n.Props, gokdl.Prop{Name: "description", Value: description})
+	}
+	return n
+}
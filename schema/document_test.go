@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoc(t *testing.T) {
+	// Arrange
+	doc, err := gokdl.ParseString(`
+workers 4
+server "api" port=(u16)8080 {
+    tls cert="api.pem"
+}
+server "admin" "backup" port=(u16)9090 ratio=0.5 {
+    tls cert="admin.pem"
+    tls cert="admin2.pem"
+}`)
+	require.NoError(t, err)
+	s := Infer(doc)
+
+	// Act
+	out := s.String()
+
+	// Assert
+	require.Equal(t, `document {
+    node "workers" {
+        min 1
+        max 1
+        value {
+            type "number"
+            format "i64"
+        }
+    }
+    node "server" {
+        min 1
+        value {
+            type "string"
+        }
+        value {
+            min 0
+            type "string"
+        }
+        prop "port" {
+            required true
+            type "number"
+            format "u16"
+        }
+        prop "ratio" {
+            type "number"
+            format "f64"
+        }
+        children {
+            node "tls" {
+                min 1
+                prop "cert" {
+                    required true
+                    type "string"
+                }
+            }
+        }
+    }
+}
+`, out)
+}
+
+func TestDocParse(t *testing.T) {
+	// Arrange
+	min, max := 1.0, 2.5
+	s := &Schema{Nodes: []*Node{{
+		Name:        "server",
+		Description: "A server",
+		Min:         1,
+		Max:         2,
+		Args: []*Arg{
+			{Value: Value{Type: TypeString, Pattern: "^[a-z]+$"}, Description: "The name"},
+			{Value: Value{Enum: []any{"a", int64(1), nil}}, Optional: true, Repeated: true},
+		},
+		Props: []*Prop{
+			{Name: "ratio", Required: true, Value: Value{Type: TypeNumber, Format: "f32", Min: &min, Max: &max}},
+		},
+		Children: []*Node{{Name: "tls", Max: 1}},
+	}}}
+
+	// Act
+	parsed, err := Parse(s.Doc())
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, s, parsed)
+}
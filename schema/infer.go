@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"github.com/lunjon/gokdl"
+)
+
+// Infer returns the schema that the documents are examples of.
+//
+// The nodes, arguments and properties are described in the order
+// that they first occur. A node is required, with min 1, if it occurs
+// in every instance of its parent, and at most once, with max 1, if it
+// never occurs more than once in the same parent. An argument or a
+// property is required if every instance of the node has it.
+//
+// The type of a value is the type of every example of it, ignoring
+// nulls, or any type if they differ. The format of a number is its
+// type annotation if every example has the same, else "i64" if every
+// example is an integer, and otherwise "f64".
+func Infer(docs ...gokdl.Doc) *Schema {
+	parents := make([][]gokdl.Node, len(docs))
+	for i, doc := range docs {
+		parents[i] = doc.Nodes()
+	}
+	return &Schema{Nodes: inferNodes(parents)}
+}
+
+// inferNodes returns the nodes that are children of the parents,
+// which is the children of each instance of a node.
+func inferNodes(parents [][]gokdl.Node) []*Node {
+	var defs []*Node
+	instances := map[string][]gokdl.Node{}
+	// The number of parents that the node occurs in
+	occurs := map[string]int{}
+	for _, children := range parents {
+		counts := map[string]int{}
+		for _, c := range children {
+			if _, ok := instances[c.Name]; !ok {
+				defs = append(defs, &Node{Name: c.Name, Max: 1})
+			}
+			instances[c.Name] = append(instances[c.Name], c)
+			counts[c.Name]++
+		}
+		for name, count := range counts {
+			occurs[name]++
+			if count > 1 {
+				node := lookup(defs, name)
+				node.Max = 0
+			}
+		}
+	}
+
+	for _, def := range defs {
+		nodes := instances[def.Name]
+		if occurs[def.Name] == len(parents) {
+			def.Min = 1
+		}
+		def.Args = inferArgs(nodes)
+		def.Props = inferProps(nodes)
+
+		children := make([][]gokdl.Node, len(nodes))
+		for i, n := range nodes {
+			children[i] = n.Children
+		}
+		def.Children = inferNodes(children)
+	}
+	return defs
+}
+
+func inferArgs(nodes []gokdl.Node) []*Arg {
+	var args []*Arg
+	for i := 0; ; i++ {
+		var values []value
+		for _, n := range nodes {
+			if i < len(n.Args) {
+				values = append(values, value{n.Args[i].Value, n.Args[i].TypeAnnotation})
+			}
+		}
+		if len(values) == 0 {
+			return args
+		}
+		args = append(args, &Arg{
+			Value:    inferValue(values),
+			Optional: len(values) < len(nodes),
+		})
+	}
+}
+
+func inferProps(nodes []gokdl.Node) []*Prop {
+	var props []*Prop
+	values := map[string][]value{}
+	for _, n := range nodes {
+		for _, p := range n.Props {
+			if _, ok := values[p.Name]; !ok {
+				props = append(props, &Prop{Name: p.Name})
+			}
+			values[p.Name] = append(values[p.Name], value{p.Value, p.ValueTypeAnnot})
+		}
+	}
+
+	for _, p := range props {
+		p.Value = inferValue(values[p.Name])
+		p.Required = len(values[p.Name]) == len(nodes)
+	}
+	return props
+}
+
+// value is an example of a value, with its type annotation.
+type value struct {
+	value any
+	annot gokdl.TypeAnnotation
+}
+
+func inferValue(values []value) Value {
+	v := Value{}
+	formats := map[string]bool{}
+	float := false
+	for _, ex := range values {
+		typ := typeOf(ex.value)
+		if typ == TypeNull {
+			continue
+		}
+		if v.Type == "" {
+			v.Type = typ
+		} else if v.Type != typ {
+			return Value{}
+		}
+
+		formats[string(ex.annot)] = true
+		if _, ok := ex.value.(float64); ok {
+			float = true
+		}
+	}
+
+	if v.Type == "" {
+		// Only nulls, or no examples
+		if len(values) > 0 {
+			v.Type = TypeNull
+		}
+		return v
+	}
+	if len(formats) == 1 {
+		for format := range formats {
+			v.Format = format
+		}
+	}
+	if v.Type == TypeNumber && v.Format == "" {
+		v.Format = "i64"
+		if float {
+			v.Format = "f64"
+		}
+	}
+	return v
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return TypeNull
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	}
+	return TypeNumber
+}
+
+func lookup(nodes []*Node, name string) *Node {
+	for _, n := range nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
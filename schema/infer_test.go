@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfer(t *testing.T) {
+	// Arrange
+	first, err := gokdl.ParseString(`
+workers 4
+server "api" port=(u16)8080 timeout="1m" {
+    upstream "10.0.0.1" 9000
+    upstream "10.0.0.2" 9000
+    tls cert="api.pem"
+}
+server "admin" port=(u16)9090 ratio=1`)
+	require.NoError(t, err)
+	second, err := gokdl.ParseString(`
+server "web" port=(u16)80 ratio=0.5 timeout=null {
+    tls cert="web.pem" key=1
+}
+debug`)
+	require.NoError(t, err)
+
+	// Act
+	s := Infer(first, second)
+
+	// Assert
+	require.Equal(t, &Schema{Nodes: []*Node{
+		{
+			Name: "workers",
+			Max:  1,
+			Args: []*Arg{{Value: Value{Type: TypeNumber, Format: "i64"}}},
+		},
+		{
+			Name: "server",
+			Min:  1,
+			Args: []*Arg{{Value: Value{Type: TypeString}}},
+			Props: []*Prop{
+				{Name: "port", Required: true, Value: Value{Type: TypeNumber, Format: "u16"}},
+				{Name: "timeout", Value: Value{Type: TypeString}},
+				{Name: "ratio", Value: Value{Type: TypeNumber, Format: "f64"}},
+			},
+			Children: []*Node{
+				{
+					Name: "upstream",
+					Args: []*Arg{
+						{Value: Value{Type: TypeString}},
+						{Value: Value{Type: TypeNumber, Format: "i64"}},
+					},
+				},
+				{
+					Name: "tls",
+					Max:  1,
+					Props: []*Prop{
+						{Name: "cert", Required: true, Value: Value{Type: TypeString}},
+						{Name: "key", Value: Value{Type: TypeNumber, Format: "i64"}},
+					},
+				},
+			},
+		},
+		{Name: "debug", Max: 1},
+	}}, s)
+}
+
+func TestInferValues(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		expected []*Arg
+	}{
+		{"mixed types", `n 1; n "a"`, []*Arg{{}}},
+		{"nulls", `n null; n null`, []*Arg{{Value: Value{Type: TypeNull}}}},
+		{"null and string", `n null; n "a"`, []*Arg{{Value: Value{Type: TypeString}}}},
+		{"mixed formats", `n (u8)1; n (i32)2`, []*Arg{{Value: Value{Type: TypeNumber, Format: "i64"}}}},
+		{"annotated string", `n (date)"2024-01-01"`, []*Arg{{Value: Value{Type: TypeString, Format: "date"}}}},
+		{"optional", `n 1 true; n 2`, []*Arg{
+			{Value: Value{Type: TypeNumber, Format: "i64"}},
+			{Value: Value{Type: TypeBoolean}, Optional: true},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Arrange
+			doc, err := gokdl.ParseString(test.body)
+			require.NoError(t, err)
+
+			// Act
+			s := Infer(doc)
+
+			// Assert
+			require.Equal(t, test.expected, s.Nodes[0].Args)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/lunjon/gokdl"
+)
+
+// ParseFile parses the KDL Schema document in the file at path.
+func ParseFile(path string) (*Schema, error) {
+	doc, err := gokdl.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse returns the schema described by the KDL Schema document doc,
+// i.e. by the node definitions in its document node. Validations
+// that are not part of the schema, see the package documentation,
+// are ignored.
+func Parse(doc gokdl.Doc) (*Schema, error) {
+	s := &Schema{}
+	for _, n := range doc.Nodes() {
+		if n.Name != "document" {
+			continue
+		}
+		nodes, err := parseNodes(n.Children, "document")
+		if err != nil {
+			return nil, err
+		}
+		s.Nodes = append(s.Nodes, nodes...)
+	}
+	return s, nil
+}
+
+// parseNodes returns the node definitions in nodes, the
+// children of a document or children node at path.
+func parseNodes(nodes []gokdl.Node, path string) ([]*Node, error) {
+	var defs []*Node
+	for _, n := range nodes {
+		if n.Name != "node" {
+			continue
+		}
+		name, err := stringArg(n, path)
+		if err != nil {
+			return nil, err
+		}
+
+		def := &Node{Name: name, Description: description(n)}
+		path := path + "." + name
+		for _, c := range n.Children {
+			switch c.Name {
+			case "min", "max":
+				count, err := intArg(c, path)
+				if err != nil {
+					return nil, err
+				}
+				if c.Name == "min" {
+					def.Min = count
+				} else {
+					def.Max = count
+				}
+			case "value":
+				arg, err := parseArg(c, path)
+				if err != nil {
+					return nil, err
+				}
+				if len(def.Args) > 0 && def.Args[len(def.Args)-1].Repeated {
+					return nil, fmt.Errorf("%s: only the last argument may be repeated", path)
+				}
+				def.Args = append(def.Args, arg)
+			case "prop":
+				prop, err := parseProp(c, path)
+				if err != nil {
+					return nil, err
+				}
+				def.Props = append(def.Props, prop)
+			case "children":
+				children, err := parseNodes(c.Children, path)
+				if err != nil {
+					return nil, err
+				}
+				def.Children = append(def.Children, children...)
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+func parseArg(n gokdl.Node, path string) (*Arg, error) {
+	arg := &Arg{Description: description(n)}
+	for _, c := range n.Children {
+		switch c.Name {
+		case "min":
+			min, err := intArg(c, path)
+			if err != nil {
+				return nil, err
+			}
+			if min > 1 {
+				return nil, fmt.Errorf("%s: value: min must be 0 or 1", path)
+			}
+			arg.Optional = min == 0
+		case "repeated":
+			repeated, ok := singleArg(c).(bool)
+			if !ok {
+				return nil, fmt.Errorf("%s: value: repeated: expected a boolean", path)
+			}
+			arg.Repeated = repeated
+		}
+	}
+	return arg, parseValue(n, &arg.Value, path+": value")
+}
+
+func parseProp(n gokdl.Node, path string) (*Prop, error) {
+	name, err := stringArg(n, path)
+	if err != nil {
+		return nil, err
+	}
+
+	prop := &Prop{Name: name, Description: description(n)}
+	path = fmt.Sprintf("%s: property %q", path, name)
+	for _, c := range n.Children {
+		if c.Name != "required" {
+			continue
+		}
+		required, ok := singleArg(c).(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s: required: expected a boolean", path)
+		}
+		prop.Required = required
+	}
+	return prop, parseValue(n, &prop.Value, path)
+}
+
+// parseValue sets the validations of v from the children of n.
+func parseValue(n gokdl.Node, v *Value, path string) error {
+	for _, c := range n.Children {
+		switch c.Name {
+		case "type", "format", "pattern":
+			s, ok := singleArg(c).(string)
+			if !ok {
+				return fmt.Errorf("%s: %s: expected a string", path, c.Name)
+			}
+			switch c.Name {
+			case "type":
+				v.Type = s
+			case "format":
+				v.Format = s
+			case "pattern":
+				v.Pattern = s
+			}
+		case "enum":
+			v.Enum = []any{}
+			for _, a := range c.Args {
+				v.Enum = append(v.Enum, a.Value)
+			}
+		case ">=", "<=":
+			bound, ok := number(singleArg(c))
+			if !ok {
+				return fmt.Errorf("%s: %s: expected a number", path, c.Name)
+			}
+			if c.Name == ">=" {
+				v.Min = &bound
+			} else {
+				v.Max = &bound
+			}
+		}
+	}
+
+	switch v.Type {
+	case "", TypeString, TypeNumber, TypeBoolean, TypeNull:
+		return nil
+	}
+	return fmt.Errorf("%s: invalid type %q", path, v.Type)
+}
+
+func stringArg(n gokdl.Node, path string) (string, error) {
+	if len(n.Args) > 0 {
+		if s, ok := n.Args[0].Value.(string); ok {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %s: expected a name", path, n.Name)
+}
+
+func intArg(n gokdl.Node, path string) (int, error) {
+	if v, ok := singleArg(n).(int64); ok && v >= 0 {
+		return int(v), nil
+	}
+	return 0, fmt.Errorf("%s: %s: expected a non-negative integer", path, n.Name)
+}
+
+// singleArg returns the value of the single argument
+// of n, or nil if it has none or more than one.
+func singleArg(n gokdl.Node) any {
+	if len(n.Args) != 1 {
+		return nil
+	}
+	return n.Args[0].Value
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func description(n gokdl.Node) string {
+	for _, p := range n.Props {
+		if p.Name == "description" {
+			if s, ok := p.Value.(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
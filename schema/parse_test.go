@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/lunjon/gokdl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	// Arrange
+	doc, err := gokdl.ParseString(`
+document {
+    info {
+        title "Servers"
+    }
+    node "server" description="A server" {
+        min 1
+        value {
+            type "string"
+            pattern "^[a-z]+$"
+        }
+        value {
+            type "string"
+            min 0
+            repeated true
+        }
+        prop "port" {
+            type "number"
+            format "u16"
+            required true
+            ">=" 1
+            "<=" 65535
+        }
+        prop "level" {
+            enum "debug" "info"
+        }
+        children {
+            node "tls" {
+                max 1
+            }
+        }
+    }
+}`)
+	require.NoError(t, err)
+
+	// Act
+	s, err := Parse(doc)
+
+	// Assert
+	require.NoError(t, err)
+	min, max := 1.0, 65535.0
+	require.Equal(t, &Schema{Nodes: []*Node{{
+		Name:        "server",
+		Description: "A server",
+		Min:         1,
+		Args: []*Arg{
+			{Value: Value{Type: TypeString, Pattern: "^[a-z]+$"}},
+			{Value: Value{Type: TypeString}, Optional: true, Repeated: true},
+		},
+		Props: []*Prop{
+			{Name: "port", Required: true, Value: Value{Type: TypeNumber, Format: "u16", Min: &min, Max: &max}},
+			{Name: "level", Value: Value{Enum: []any{"debug", "info"}}},
+		},
+		Children: []*Node{{Name: "tls", Max: 1}},
+	}}}, s)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		testname string
+		body     string
+		message  string
+	}{
+		{"no name", `node { min 1; }`, "document: node: expected a name"},
+		{"invalid min", `node "a" { min -1; }`, "document.a: min: expected a non-negative integer"},
+		{"invalid type", `node "a" { prop "b" { type "date"; }; }`, `document.a: property "b": invalid type "date"`},
+		{"invalid required", `node "a" { prop "b" { required "yes"; }; }`, `document.a: property "b": required: expected a boolean`},
+		{"invalid bound", `node "a" { value { ">=" "1"; }; }`, `document.a: value: >=: expected a number`},
+		{"invalid arg min", `node "a" { value { min 2; }; }`, "document.a: value: min must be 0 or 1"},
+		{"repeated not last", `node "a" { value { repeated true; }; value; }`, "document.a: only the last argument may be repeated"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.testname, func(t *testing.T) {
+			// Arrange
+			doc, err := gokdl.ParseString("document {\n" + test.body + "\n}")
+			require.NoError(t, err)
+
+			// Act
+			_, err = Parse(doc)
+
+			// Assert
+			require.EqualError(t, err, test.message)
+		})
+	}
+}
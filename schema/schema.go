@@ -0,0 +1,95 @@
+// Package schema reads KDL Schema documents, and infers them from
+// example documents.
+//
+// A schema is the subset of the KDL Schema spec that describes the
+// nodes of a document, their arguments, properties and children:
+//
+//	document {
+//	    node "server" description="A server" {
+//	        min 1
+//	        value {
+//	            type "string"
+//	        }
+//	        prop "port" {
+//	            type "number"
+//	            format "u16"
+//	            required true
+//	            ">=" 1
+//	        }
+//	        children {
+//	            node "tls" {
+//	                max 1
+//	            }
+//	        }
+//	    }
+//	}
+//
+// The min and max of a node are the number of times that it may occur
+// among its siblings, and are unbounded by default. Each value node
+// describes the next argument. An argument is optional if the value
+// node has min 0, and may occur any number of times if it has
+// repeated true, which only the last argument may have.
+//
+// The validations of a value are its type, one of "string", "number",
+// "boolean" and "null", its format, e.g. the type annotation "u8" of a
+// number, enum, pattern, and the bounds ">=" and "<=" of a number.
+package schema
+
+// Schema describes the nodes of a document.
+type Schema struct {
+	Nodes []*Node
+}
+
+// Node describes the nodes with a name.
+type Node struct {
+	Name        string
+	Description string
+	// Min is the least number of times that the node occurs
+	// among its siblings, and Max the most, or 0 if unbounded.
+	Min, Max int
+	Args     []*Arg
+	Props    []*Prop
+	Children []*Node
+}
+
+// Arg describes an argument of a node.
+type Arg struct {
+	Value
+	Description string
+	// Optional is true if the argument may be left out.
+	Optional bool
+	// Repeated is true if the argument may occur any number
+	// of times, i.e. it is the rest of the arguments.
+	Repeated bool
+}
+
+// Prop describes a property of a node.
+type Prop struct {
+	Value
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Value is the validations of an argument or the value of a property.
+type Value struct {
+	// Type is "string", "number", "boolean" or "null",
+	// or empty if the value may be of any type.
+	Type string
+	// Format is e.g. the type annotation of a number, like "u8".
+	Format string
+	// Enum is the values that the value may be, if any.
+	Enum []any
+	// Pattern is the regular expression that a string must match.
+	Pattern string
+	// Min and Max are the bounds of a number.
+	Min, Max *float64
+}
+
+// The types of values.
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
@@ -0,0 +1,36 @@
+// Command bench writes the documents used in the benchmarks
+// to a directory, e.g. for profiling or comparing with other
+// parsers:
+//
+//	go run ./testdata/bench -out /tmp/kdl-bench
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/lunjon/gokdl/internal/benchdata"
+)
+
+func main() {
+	out := flag.String("out", ".", "directory to write the documents to")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, shape := range benchdata.Shapes {
+		for _, size := range benchdata.Sizes {
+			doc := benchdata.Generate(shape, size.Bytes)
+			filename := filepath.Join(*out, fmt.Sprintf("%s-%s.kdl", shape, size.Name))
+			if err := os.WriteFile(filename, []byte(doc), 0o644); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(filename)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gokdl
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// constraints are the options in the tag of a
+// field that its decoded value is validated by.
+type constraints struct {
+	// The bounds of a number, or of the length
+	// of a string, a slice or a map.
+	min, max *float64
+	// The values that a value may be, as formatted by text.
+	oneof []string
+	// The pattern that a value, as formatted by text, must match.
+	pattern *regexp.Regexp
+}
+
+// validate records an error for each constraint that the value v,
+// decoded from the item at pos and path, violates. The values of a
+// slice are validated one by one, except for their number.
+func (ds *decodeState) validate(c constraints, v reflect.Value, pos Position, path string) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if isValue(v) {
+		ds.validateValue(c, v, pos, path)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		ds.bounds(c, "length", float64(v.Len()), pos, path)
+		for i := 0; i < v.Len(); i++ {
+			ds.validateValue(c.values(), v.Index(i), pos, itemPath(path, fmt.Sprintf("value %d", i+1)))
+		}
+	case reflect.Map:
+		ds.bounds(c, "length", float64(v.Len()), pos, path)
+	}
+}
+
+// validateValue validates the single value v, see validate.
+func (ds *decodeState) validateValue(c constraints, v reflect.Value, pos Position, path string) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ds.bounds(c, "value", float64(v.Int()), pos, path)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		ds.bounds(c, "value", float64(v.Uint()), pos, path)
+	case reflect.Float32, reflect.Float64:
+		ds.bounds(c, "value", v.Float(), pos, path)
+	case reflect.String:
+		ds.bounds(c, "length", float64(len(v.String())), pos, path)
+	}
+
+	if c.oneof == nil && c.pattern == nil {
+		return
+	}
+	s := text(v)
+	if c.oneof != nil && !slices.Contains(c.oneof, s) {
+		ds.fail(path, pos, fmt.Errorf("value %q is not one of %s", s, strings.Join(c.oneof, ", ")))
+	}
+	if c.pattern != nil && !c.pattern.MatchString(s) {
+		ds.fail(path, pos, fmt.Errorf("value %q does not match the pattern %s", s, c.pattern))
+	}
+}
+
+// bounds records an error if the number n, which is
+// what is named, is outside of the bounds of c.
+func (ds *decodeState) bounds(c constraints, what string, n float64, pos Position, path string) {
+	if c.min != nil && n < *c.min {
+		ds.fail(path, pos, fmt.Errorf("%s %s is less than the minimum %s", what, formatBound(n), formatBound(*c.min)))
+	}
+	if c.max != nil && n > *c.max {
+		ds.fail(path, pos, fmt.Errorf("%s %s is greater than the maximum %s", what, formatBound(n), formatBound(*c.max)))
+	}
+}
+
+// values returns the constraints that the values of a slice are
+// validated by, i.e. all but the bounds, which are of its length.
+func (c constraints) values() constraints {
+	return constraints{oneof: c.oneof, pattern: c.pattern}
+}
+
+// text returns the value v formatted as text, by its
+// encoding.TextMarshaler if it is one, for oneof and pattern.
+func text(v reflect.Value) string {
+	if m, ok := marshaler[encoding.TextMarshaler](v); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func formatBound(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}